@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/leonidSpiri/go-homework/monitor"
+)
+
+type alertFormatter interface {
+	format(a monitor.Alert) (string, error)
+}
+
+// textFormatter печатает сообщение в прежнем человекочитаемом виде,
+// критические тревоги помечаются префиксом CRITICAL.
+// С color тревоги выделяются красным, а возврат в норму — зелёным.
+type textFormatter struct {
+	withServer bool
+	color      bool
+}
+
+func (f textFormatter) format(a monitor.Alert) (string, error) {
+	msg := a.Message
+	if a.Status == monitor.StatusFiring && a.Severity == monitor.SeverityCritical {
+		msg = "CRITICAL: " + msg
+	}
+	if f.withServer {
+		msg = "[" + a.Server + "] " + msg
+	}
+	if f.color {
+		switch a.Status {
+		case monitor.StatusFiring:
+			msg = colorize(msg, ansiRed)
+		case monitor.StatusResolved:
+			msg = colorize(msg, ansiGreen)
+		}
+	}
+	return msg, nil
+}
+
+// jsonFormatter печатает каждое сообщение отдельным JSON-объектом в строку.
+type jsonFormatter struct{}
+
+func (jsonFormatter) format(a monitor.Alert) (string, error) {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func newFormatter(name string, multiServer, color bool) (alertFormatter, error) {
+	switch name {
+	case "text":
+		return textFormatter{withServer: multiServer, color: color}, nil
+	case "json":
+		return jsonFormatter{}, nil
+	}
+	return nil, fmt.Errorf("invalid -format %q: must be text or json", name)
+}
+
+// alertState хранит метрики, по которым уже было сообщение о превышении,
+// ключ — имя метрики, значение — уровень тревоги. У каждого сервера своё
+// состояние.
+type alertState map[string]string
+
+// update возвращает только изменения: первое превышение порога, смену
+// уровня тревоги и возврат в норму для метрик, которые до этого были
+// в состоянии тревоги.
+// hysteresis — доля порога, на которую значение должно опуститься ниже него,
+// чтобы тревога снялась: при пороге 80% и hysteresis 0.1 — ниже 72%.
+func (s alertState) update(alerts []monitor.Alert, hysteresis float64) []monitor.Alert {
+	var out []monitor.Alert
+	for _, a := range alerts {
+		switch {
+		case a.Status == monitor.StatusFiring && s[a.Metric] != a.Severity:
+			s[a.Metric] = a.Severity
+			out = append(out, a)
+		case a.Status == monitor.StatusOK && s[a.Metric] != "" && a.InDeadband(hysteresis):
+			// значение в мёртвой зоне: тревога остаётся
+		case a.Status == monitor.StatusOK && s[a.Metric] != "":
+			delete(s, a.Metric)
+			a.Status = monitor.StatusResolved
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// firing возвращает проверки из alerts, по которым держится тревога, в том
+// числе в мёртвой зоне: со статусом StatusFiring и уровнем из состояния.
+func (s alertState) firing(alerts []monitor.Alert) []monitor.Alert {
+	var out []monitor.Alert
+	for _, a := range alerts {
+		if severity := s[a.Metric]; severity != "" {
+			a.Status, a.Severity = monitor.StatusFiring, severity
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// breachCounter пропускает превышение порога, только если оно держится
+// need опросов подряд; до этого проверка считается пройденной.
+type breachCounter struct {
+	need  int
+	count map[string]int
+}
+
+func newBreachCounter(need int) *breachCounter {
+	return &breachCounter{need: need, count: make(map[string]int)}
+}
+
+func (c *breachCounter) filter(alerts []monitor.Alert) []monitor.Alert {
+	out := make([]monitor.Alert, 0, len(alerts))
+	for _, a := range alerts {
+		if a.Status != monitor.StatusFiring {
+			delete(c.count, a.Metric)
+		} else if c.count[a.Metric]++; c.count[a.Metric] < c.need {
+			a.Status = monitor.StatusOK
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// alertReminder повторяет оповещение о тревоге, которая держится дольше
+// every, не чаще раза в every и с пометкой, сколько она уже длится.
+type alertReminder struct {
+	every time.Duration
+	since map[string]time.Time // начало тревоги
+	last  map[string]time.Time // последнее оповещение о ней
+}
+
+func newAlertReminder(every time.Duration) *alertReminder {
+	return &alertReminder{every: every, since: make(map[string]time.Time), last: make(map[string]time.Time)}
+}
+
+// remind получает все результаты проверок и изменения из alertState.update
+// за тот же опрос и возвращает напоминания о продолжающихся тревогах.
+func (r *alertReminder) remind(alerts, changed []monitor.Alert, now time.Time) []monitor.Alert {
+	if r.every <= 0 {
+		return nil
+	}
+	notified := make(map[string]bool, len(changed))
+	for _, a := range changed {
+		notified[a.Metric] = true
+		switch a.Status {
+		case monitor.StatusFiring:
+			if _, ok := r.since[a.Metric]; !ok {
+				r.since[a.Metric] = now
+			}
+			r.last[a.Metric] = now
+		case monitor.StatusResolved:
+			delete(r.since, a.Metric)
+			delete(r.last, a.Metric)
+		}
+	}
+	var out []monitor.Alert
+	for _, a := range alerts {
+		since, active := r.since[a.Metric]
+		if a.Status != monitor.StatusFiring || !active || notified[a.Metric] || now.Sub(r.last[a.Metric]) < r.every {
+			continue
+		}
+		r.last[a.Metric] = now
+		a.Message += fmt.Sprintf(" (still firing, %s)", fmtDuration(now.Sub(since)))
+		out = append(out, a)
+	}
+	return out
+}
+
+// fmtDuration печатает длительность до минут: 45s, 12m, 1h5m.
+func fmtDuration(d time.Duration) string {
+	if d < time.Minute {
+		return d.Round(time.Second).String()
+	}
+	s := strings.TrimSuffix(d.Round(time.Minute).String(), "0s")
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// fetchFailedAlert сообщает, что статистику не удалось получить failures
+// опросов подряд; err — последняя ошибка.
+func fetchFailedAlert(server, msg string, failures, threshold int, err error, now time.Time) monitor.Alert {
+	return monitor.Alert{
+		Metric:    monitor.MetricFetch,
+		Status:    monitor.StatusFiring,
+		Severity:  monitor.SeverityWarning,
+		Value:     float64(failures),
+		Threshold: float64(threshold),
+		Server:    server,
+		Timestamp: now,
+		Message:   msg,
+		Error:     err.Error(),
+	}
+}
+
+// statusAlert описывает неудачный код ответа по его виду: сбой на стороне
+// агента, отсутствующий адрес статистики, отказ в доступе.
+func statusAlert(server string, se *monitor.StatusError, now time.Time) monitor.Alert {
+	severity, msg := monitor.SeverityWarning, "Unexpected stats response"
+	switch {
+	case se.Code >= 500:
+		severity, msg = monitor.SeverityCritical, "Stats server error"
+	case se.Code == http.StatusNotFound, se.Code == http.StatusGone:
+		msg = "Stats endpoint missing"
+	case se.Code == http.StatusUnauthorized, se.Code == http.StatusForbidden:
+		msg = "Stats access denied"
+	case se.Code == http.StatusTooManyRequests:
+		msg = "Stats endpoint rate limited"
+	}
+	return monitor.Alert{
+		Metric:    monitor.MetricHTTPStatus,
+		Status:    monitor.StatusFiring,
+		Severity:  severity,
+		Value:     float64(se.Code),
+		Threshold: http.StatusOK,
+		Server:    server,
+		Timestamp: now,
+		Message:   msg + ": " + se.Status,
+		Error:     se.Error(),
+	}
+}
+
+func statusResolvedAlert(server string, now time.Time) monitor.Alert {
+	return monitor.Alert{
+		Metric:    monitor.MetricHTTPStatus,
+		Status:    monitor.StatusResolved,
+		Value:     http.StatusOK,
+		Threshold: http.StatusOK,
+		Server:    server,
+		Timestamp: now,
+		Message:   "Stats endpoint back to normal: 200 OK",
+	}
+}
+
+// alertCooldown подавляет повтор оповещения той же метрики с тем же статусом,
+// пока с последнего вывода не прошло window.
+type alertCooldown struct {
+	window time.Duration
+	last   map[string]time.Time
+}
+
+func newAlertCooldown(window time.Duration) *alertCooldown {
+	return &alertCooldown{window: window, last: make(map[string]time.Time)}
+}
+
+func (c *alertCooldown) filter(alerts []monitor.Alert, now time.Time) []monitor.Alert {
+	if c.window <= 0 {
+		return alerts
+	}
+	var out []monitor.Alert
+	for _, a := range alerts {
+		key := a.Metric + "/" + a.Status + "/" + a.Severity
+		if last, ok := c.last[key]; ok && now.Sub(last) < c.window {
+			continue
+		}
+		c.last[key] = now
+		out = append(out, a)
+	}
+	return out
+}
+
+func writeAlerts(out *log.Logger, f alertFormatter, alerts []monitor.Alert) {
+	for _, a := range alerts {
+		line, err := f.format(a)
+		if err != nil {
+			log.Printf("format %s alert: %v", a.Metric, err)
+			continue
+		}
+		out.Println(line)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/leonidSpiri/go-homework/monitor"
+)
+
+// checkInfo описывает одну проверку для -list-checks.
+type checkInfo struct {
+	metric    string
+	condition string
+	message   string
+}
+
+// describeChecks перечисляет проверки с порогами из итоговых настроек,
+// то есть с учётом флагов, переменных окружения и -config файла.
+func describeChecks(cfg config) []checkInfo {
+	disabled := cfg.disabledChecks()
+	ratio := func(v float64) string { return fmt.Sprintf("> %s%%", fmtFloat(v*100)) }
+	checks := []checkInfo{
+		{monitor.MetricLoadAvg, "> " + fmtFloat(cfg.LoadLimit), "Load Average is too high: {value}"},
+		{monitor.MetricMemory, ratio(cfg.MemLimit) + " used", "Memory usage too high: {value}%"},
+		{monitor.MetricDisk, ratio(cfg.DiskLimit) + " used", "Free disk space is too low: {free} left"},
+		{monitor.MetricNetwork, ratio(cfg.NetLimit) + " used", "Network bandwidth usage high: {free} Mbit/s available"},
+		{monitor.MetricSwap, ratio(cfg.SwapLimit) + " used", "Swap usage too high: {value}%"},
+		{monitor.MetricTemperature, "> " + fmtFloat(cfg.TempLimit) + "C", "CPU temperature too high: {value}C"},
+	}
+	crit := cfg.critThresholds()
+	crits := map[string]string{
+		monitor.MetricLoadAvg:     "> " + fmtFloat(crit.LoadAvg),
+		monitor.MetricMemory:      ratio(crit.MemUsage),
+		monitor.MetricDisk:        ratio(crit.DiskUsage),
+		monitor.MetricNetwork:     ratio(crit.NetworkUsage),
+		monitor.MetricSwap:        ratio(crit.SwapUsage),
+		monitor.MetricTemperature: "> " + fmtFloat(crit.Temperature) + "C",
+	}
+	critSet := map[string]bool{
+		monitor.MetricLoadAvg:     crit.LoadAvg > 0,
+		monitor.MetricMemory:      crit.MemUsage > 0,
+		monitor.MetricDisk:        crit.DiskUsage > 0,
+		monitor.MetricNetwork:     crit.NetworkUsage > 0,
+		monitor.MetricSwap:        crit.SwapUsage > 0,
+		monitor.MetricTemperature: crit.Temperature > 0,
+	}
+	for i, c := range checks {
+		switch {
+		case disabled[c.metric] || c.metric == monitor.MetricTemperature && cfg.TempLimit == 0:
+			checks[i].condition = "disabled"
+		case critSet[c.metric]:
+			checks[i].condition += ", critical " + crits[c.metric]
+		}
+	}
+	// нижние границы включаются явно, без них строка только напоминает о флаге
+	below := func(v float64) string { return fmt.Sprintf("< %s%%", fmtFloat(v*100)) }
+	floors := []struct {
+		info checkInfo
+		set  bool
+	}{
+		{checkInfo{monitor.MetricLoadLow, "< " + fmtFloat(cfg.LoadFloor), "Load Average is suspiciously low: {value}"}, cfg.LoadFloor > 0},
+		{checkInfo{monitor.MetricMemoryLow, below(cfg.MemFloor) + " used", "Memory usage suspiciously low: {value}%"}, cfg.MemFloor > 0},
+		{checkInfo{monitor.MetricDiskLow, below(cfg.DiskFloor) + " used", "Disk usage suspiciously low: {value}% used"}, cfg.DiskFloor > 0},
+		{checkInfo{monitor.MetricNetworkLow, below(cfg.NetFloor) + " used", "Network bandwidth usage suspiciously low: {value}% used"}, cfg.NetFloor > 0},
+		{checkInfo{monitor.MetricSwapLow, below(cfg.SwapFloor) + " used", "Swap usage suspiciously low: {value}%"}, cfg.SwapFloor > 0},
+		{checkInfo{monitor.MetricTemperatureLow, "< " + fmtFloat(cfg.TempFloor) + "C", "CPU temperature suspiciously low: {value}C"}, cfg.TempFloor > 0},
+	}
+	for _, f := range floors {
+		if !f.set || disabled[strings.TrimSuffix(f.info.metric, "_low")] {
+			f.info.condition = "disabled"
+		}
+		checks = append(checks, f.info)
+	}
+	for _, r := range cfg.Rules {
+		checks = append(checks, checkInfo{
+			metric:    r.Name,
+			condition: fmt.Sprintf("field %d %s %s", r.Field, r.Op, fmtFloat(r.Limit)),
+			message:   r.Message,
+		})
+	}
+	return checks
+}
+
+// thresholdSummary перечисляет включённые проверки с порогами для строки
+// при запуске, в том же виде, что и -list-checks.
+func thresholdSummary(cfg config) string {
+	var parts []string
+	for _, c := range describeChecks(cfg) {
+		if c.condition != "disabled" {
+			parts = append(parts, c.metric+" "+c.condition)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+func listChecks(w io.Writer, cfg config) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METRIC\tALERT WHEN\tMESSAGE")
+	for _, c := range describeChecks(cfg) {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", c.metric, c.condition, c.message)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,798 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/leonidSpiri/go-homework/monitor"
+)
+
+const (
+	statsURL          = "http://srv.msk01.gigacorp.local/_stats"
+	pollInterval      = monitor.DefaultInterval
+	httpTimeout       = 3 * time.Second
+	errorThreshold    = 3
+	loadAvgLimit      = 30.0
+	memUsageLimit     = 0.80
+	diskUsageLimit    = 0.90
+	networkUsageLimit = 0.90
+	swapUsageLimit    = 0.50
+)
+
+// duration в JSON записывается строкой вида "5s", как и во флагах.
+type duration time.Duration
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"5s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+// urlList собирает значения повторяющегося флага -url, каждое значение
+// может содержать несколько адресов через запятую.
+type urlList []string
+
+func (l *urlList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *urlList) Set(v string) error {
+	for _, p := range strings.Split(v, ",") {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			*l = append(*l, p)
+		}
+	}
+	return nil
+}
+
+// config содержит все настройки программы. Значения берутся из встроенных
+// констант, затем из файла -config, окружения и явно заданных флагов.
+type config struct {
+	URLs              urlList        `json:"urls"`
+	InputFile         string         `json:"input_file"`
+	Stdin             bool           `json:"stdin"`
+	Sample            string         `json:"sample"`
+	Format            string         `json:"format"`
+	Interval          duration       `json:"interval"`
+	MaxRuntime        duration       `json:"max_runtime"`
+	Jitter            float64        `json:"jitter"`
+	SkipMissed        bool           `json:"skip_missed"`
+	StartDelay        duration       `json:"start_delay"`
+	RandomStartDelay  bool           `json:"random_start_delay"`
+	Timeout           duration       `json:"timeout"`
+	PollDeadline      duration       `json:"poll_deadline"`
+	MaxRedirects      int            `json:"max_redirects"`
+	MaxIdleConns      int            `json:"max_idle_conns"`
+	IdleConnTimeout   duration       `json:"idle_conn_timeout"`
+	DNSServer         string         `json:"dns_server"`
+	ErrorThreshold    int            `json:"error_threshold"`
+	FetchErrorMessage string         `json:"fetch_error_message"`
+	ReportFailures    bool           `json:"report_failures"`
+	StatusAlerts      bool           `json:"status_alerts"`
+	ReportEvery       int            `json:"report_every"`
+	LoadLimit         float64        `json:"load_limit"`
+	LoadWindow        int            `json:"load_window"`
+	EWMAAlpha         float64        `json:"ewma_alpha"`
+	MemLimit          float64        `json:"mem_limit"`
+	DiskLimit         float64        `json:"disk_limit"`
+	NetLimit          float64        `json:"net_limit"`
+	SwapLimit         float64        `json:"swap_limit"`
+	TempLimit         float64        `json:"temp_limit"`
+	LoadCrit          float64        `json:"load_crit"`
+	MemCrit           float64        `json:"mem_crit"`
+	DiskCrit          float64        `json:"disk_crit"`
+	NetCrit           float64        `json:"net_crit"`
+	SwapCrit          float64        `json:"swap_crit"`
+	TempCrit          float64        `json:"temp_crit"`
+	LoadFloor         float64        `json:"load_floor"`
+	MemFloor          float64        `json:"mem_floor"`
+	DiskFloor         float64        `json:"disk_floor"`
+	NetFloor          float64        `json:"net_floor"`
+	SwapFloor         float64        `json:"swap_floor"`
+	TempFloor         float64        `json:"temp_floor"`
+	Debounce          bool           `json:"debounce"`
+	Hysteresis        float64        `json:"hysteresis"`
+	RemindEvery       duration       `json:"remind_every"`
+	AlertConsecutive  int            `json:"alert_consecutive"`
+	AlertCooldown     duration       `json:"alert_cooldown"`
+	Verbose           bool           `json:"verbose"`
+	Debug             bool           `json:"debug"`
+	Quiet             bool           `json:"quiet"`
+	NoTimestamp       bool           `json:"no_timestamp"`
+	NoColor           bool           `json:"no_color"`
+	LogFile           string         `json:"log_file"`
+	Journal           bool           `json:"journal"`
+	DisableLoad       bool           `json:"disable_load"`
+	DisableMem        bool           `json:"disable_mem"`
+	DisableDisk       bool           `json:"disable_disk"`
+	DisableNet        bool           `json:"disable_net"`
+	DisableSwap       bool           `json:"disable_swap"`
+	ShowUsage         bool           `json:"show_usage"`
+	JSONSummary       bool           `json:"json_summary"`
+	Summary           bool           `json:"summary"`
+	DiskUnit          string         `json:"disk_unit"`
+	Units             string         `json:"units"`
+	ResponseFormat    string         `json:"response_format"`
+	AverageRows       bool           `json:"average_rows"`
+	Delimiter         string         `json:"delimiter"`
+	Decimal           string         `json:"decimal"`
+	Thousands         string         `json:"thousands"`
+	AuthToken         string         `json:"auth_token"`
+	User              string         `json:"user"`
+	Password          string         `json:"password"`
+	Headers           headerList     `json:"headers"`
+	CACert            string         `json:"ca_cert"`
+	Insecure          bool           `json:"insecure"`
+	ClientCert        string         `json:"client_cert"`
+	ClientKey         string         `json:"client_key"`
+	Retries           int            `json:"retries"`
+	RetryBaseDelay    duration       `json:"retry_base_delay"`
+	MaxBodySize       int64          `json:"max_body_size"`
+	ContentTypes      string         `json:"content_types"`
+	MetricsAddr       string         `json:"metrics_addr"`
+	StatsdAddr        string         `json:"statsd_addr"`
+	StatsdPrefix      string         `json:"statsd_prefix"`
+	OTLPEndpoint      string         `json:"otlp_endpoint"`
+	HealthAddr        string         `json:"health_addr"`
+	HealthMaxAge      duration       `json:"health_max_age"`
+	SlackWebhook      string         `json:"slack_webhook"`
+	WebhookURL        string         `json:"webhook_url"`
+	Rules             []monitor.Rule `json:"rules"` // только в -config файле
+}
+
+func defaultConfig() config {
+	return config{
+		Format:            "text",
+		Interval:          duration(pollInterval),
+		Timeout:           duration(httpTimeout),
+		ErrorThreshold:    errorThreshold,
+		FetchErrorMessage: "Unable to fetch server statistics.",
+		MaxRedirects:      defaultMaxRedirects,
+		AlertConsecutive:  1,
+		LoadLimit:         loadAvgLimit,
+		LoadWindow:        1,
+		MemLimit:          memUsageLimit,
+		DiskLimit:         diskUsageLimit,
+		NetLimit:          networkUsageLimit,
+		SwapLimit:         swapUsageLimit,
+		DiskUnit:          monitor.DiskUnitMB,
+		Units:             monitor.UnitsLegacy,
+		ResponseFormat:    "csv",
+		Delimiter:         ",",
+		Decimal:           ".",
+		RetryBaseDelay:    duration(defaultRetryDelay),
+		MaxBodySize:       monitor.DefaultMaxBodySize,
+	}
+}
+
+func registerFlags(fs *flag.FlagSet, c *config) {
+	fs.StringVar(&c.InputFile, "input-file", c.InputFile, "check a saved _stats response from this file instead of polling, then exit like -once")
+	fs.BoolVar(&c.Stdin, "stdin", c.Stdin, "check a _stats response read from standard input, then exit like -once")
+	fs.StringVar(&c.Sample, "sample", c.Sample, "check this literal _stats line against the thresholds and print the alerts it would trigger, then exit; nothing is sent anywhere")
+	fs.Var(&c.URLs, "url", "server statistics endpoint: http, https or a Unix socket as unix:///path/agent.sock:/_stats; repeat or comma-separate to monitor several servers (default "+statsURL+")")
+	fs.StringVar(&c.Format, "format", c.Format, "alert output format: text or json")
+	fs.DurationVar((*time.Duration)(&c.Interval), "interval", time.Duration(c.Interval), "poll interval, e.g. 1s, 30s")
+	fs.DurationVar((*time.Duration)(&c.MaxRuntime), "max-runtime", time.Duration(c.MaxRuntime), "stop after running for this long and log poll counts per server (0 runs until interrupted)")
+	fs.DurationVar((*time.Duration)(&c.StartDelay), "start-delay", time.Duration(c.StartDelay), "wait this long before the first poll")
+	fs.BoolVar(&c.RandomStartDelay, "random-start-delay", c.RandomStartDelay, "wait a random time up to -start-delay instead of the full delay")
+	fs.BoolVar(&c.SkipMissed, "skip-missed", c.SkipMissed, "after a poll that took longer than -interval, wait for the next tick instead of polling again right away")
+	fs.Float64Var(&c.Jitter, "jitter", c.Jitter, "randomly shift each poll by up to this many percent of -interval")
+	fs.DurationVar((*time.Duration)(&c.Timeout), "timeout", time.Duration(c.Timeout), "HTTP request timeout")
+	fs.DurationVar((*time.Duration)(&c.PollDeadline), "poll-deadline", time.Duration(c.PollDeadline), "hard limit for a whole poll, including retries and parsing the response (0 disables)")
+	fs.IntVar(&c.MaxIdleConns, "max-idle-conns", c.MaxIdleConns, "idle keep-alive connections to keep across all servers (0 picks one per server, at least 100)")
+	fs.DurationVar((*time.Duration)(&c.IdleConnTimeout), "idle-conn-timeout", time.Duration(c.IdleConnTimeout), "close keep-alive connections idle for longer than this (0 picks twice the -interval, at least 90s)")
+	fs.StringVar(&c.DNSServer, "dns-server", c.DNSServer, "resolve stats server names through this DNS server, host or host:port (default: the system resolver); alert sinks always use the system resolver")
+	fs.IntVar(&c.MaxRedirects, "max-redirects", c.MaxRedirects, "redirects to follow per stats request; 0 treats any redirect as a failed poll")
+	fs.IntVar(&c.ReportEvery, "report-every", c.ReportEvery, "log poll success and failure counts every N polls of each server (0 disables)")
+	fs.BoolVar(&c.StatusAlerts, "status-alerts", c.StatusAlerts, "alert right away when the agent answers with a non-200 status (5xx server error, 404 endpoint missing, ...) instead of counting it towards -error-threshold")
+	fs.BoolVar(&c.ReportFailures, "report-failures", c.ReportFailures, "print every failed poll with its cause as it happens, in addition to the -error-threshold message")
+	fs.IntVar(&c.ErrorThreshold, "error-threshold", c.ErrorThreshold, "consecutive failed polls before reporting that statistics are unavailable")
+	fs.StringVar(&c.FetchErrorMessage, "fetch-error-message", c.FetchErrorMessage, "alert message printed when -error-threshold polls in a row have failed")
+	fs.Float64Var(&c.LoadLimit, "load-limit", c.LoadLimit, "load average alert threshold")
+	fs.IntVar(&c.LoadWindow, "load-window", c.LoadWindow, "alert on the mean load average of this many last polls")
+	fs.Float64Var(&c.EWMAAlpha, "ewma-alpha", c.EWMAAlpha, "smooth memory, disk, network and swap usage with an exponentially weighted moving average of this weight for the newest poll, between 0 and 1 (0 disables)")
+	fs.Float64Var(&c.MemLimit, "mem-limit", c.MemLimit, "memory usage alert threshold, fraction between 0 and 1")
+	fs.Float64Var(&c.DiskLimit, "disk-limit", c.DiskLimit, "disk usage alert threshold, fraction between 0 and 1")
+	fs.Float64Var(&c.NetLimit, "net-limit", c.NetLimit, "network usage alert threshold, fraction between 0 and 1")
+	fs.Float64Var(&c.TempLimit, "temp-limit", c.TempLimit, "CPU temperature alert threshold in degrees Celsius, read from the 10th field (0 disables)")
+	fs.Float64Var(&c.SwapLimit, "swap-limit", c.SwapLimit, "swap usage alert threshold, fraction between 0 and 1; checked only if the server reports swap")
+	// -*-limit остаются порогом уровня warning, -*-crit добавляют второй уровень
+	fs.Float64Var(&c.LoadCrit, "load-crit", c.LoadCrit, "load average threshold for critical alerts (0 disables; -load-limit stays the warning level)")
+	fs.Float64Var(&c.MemCrit, "mem-crit", c.MemCrit, "memory usage threshold for critical alerts, fraction between 0 and 1 (0 disables)")
+	fs.Float64Var(&c.DiskCrit, "disk-crit", c.DiskCrit, "disk usage threshold for critical alerts, fraction between 0 and 1 (0 disables)")
+	fs.Float64Var(&c.NetCrit, "net-crit", c.NetCrit, "network usage threshold for critical alerts, fraction between 0 and 1 (0 disables)")
+	fs.Float64Var(&c.SwapCrit, "swap-crit", c.SwapCrit, "swap usage threshold for critical alerts, fraction between 0 and 1 (0 disables)")
+	fs.Float64Var(&c.TempCrit, "temp-crit", c.TempCrit, "CPU temperature threshold for critical alerts in degrees Celsius (0 disables)")
+	// нижние границы ловят «мёртвый» сервер: нулевую нагрузку или тишину в сети
+	fs.Float64Var(&c.LoadFloor, "load-floor", c.LoadFloor, "alert when load average drops below this value (0 disables)")
+	fs.Float64Var(&c.MemFloor, "mem-floor", c.MemFloor, "alert when memory usage drops below this fraction (0 disables)")
+	fs.Float64Var(&c.DiskFloor, "disk-floor", c.DiskFloor, "alert when disk usage drops below this fraction (0 disables)")
+	fs.Float64Var(&c.NetFloor, "net-floor", c.NetFloor, "alert when network usage drops below this fraction of capacity (0 disables)")
+	fs.Float64Var(&c.SwapFloor, "swap-floor", c.SwapFloor, "alert when swap usage drops below this fraction (0 disables)")
+	fs.Float64Var(&c.TempFloor, "temp-floor", c.TempFloor, "alert when CPU temperature drops below this value in degrees Celsius, e.g. a dead sensor (0 disables)")
+	fs.BoolVar(&c.Debounce, "debounce", c.Debounce, "report an alert only when its threshold is first crossed and again when it recovers")
+	fs.DurationVar((*time.Duration)(&c.RemindEvery), "remind-every", time.Duration(c.RemindEvery), "with -debounce, repeat a still firing alert this often, noting how long it has been firing (0 disables)")
+	fs.Float64Var(&c.Hysteresis, "hysteresis", c.Hysteresis, "with -debounce, clear an alert only once the value drops this many percent below its limit")
+	fs.IntVar(&c.AlertConsecutive, "alert-consecutive", c.AlertConsecutive, "fire an alert only after its limit is exceeded in this many polls in a row")
+	fs.DurationVar((*time.Duration)(&c.AlertCooldown), "alert-cooldown", time.Duration(c.AlertCooldown), "suppress repeats of the same alert within this window; -remind-every reminders are not suppressed (0 disables)")
+	fs.BoolVar(&c.Verbose, "verbose", c.Verbose, "log every poll result, including parsed values and individual failures")
+	fs.BoolVar(&c.Journal, "journal", c.Journal, "under systemd, write alerts to the journal with warning or critical priority instead of stdout")
+	fs.StringVar(&c.LogFile, "log-file", c.LogFile, "also append alerts to this file; reopened on SIGHUP for log rotation")
+	fs.BoolVar(&c.NoColor, "no-color", c.NoColor, "do not colorize alerts even when stdout is a terminal (also NO_COLOR)")
+	fs.BoolVar(&c.Debug, "debug", c.Debug, "log every HTTP request and response, including the raw body; credentials are redacted")
+	fs.BoolVar(&c.Quiet, "quiet", c.Quiet, "print nothing but alerts and poll failures")
+	fs.BoolVar(&c.NoTimestamp, "no-timestamp", c.NoTimestamp, "do not prefix output lines with date and time")
+	fs.BoolVar(&c.DisableLoad, "disable-load", c.DisableLoad, "skip the load average check")
+	fs.BoolVar(&c.DisableMem, "disable-mem", c.DisableMem, "skip the memory check")
+	fs.BoolVar(&c.DisableDisk, "disable-disk", c.DisableDisk, "skip the disk space check")
+	fs.BoolVar(&c.DisableNet, "disable-net", c.DisableNet, "skip the network bandwidth check")
+	fs.BoolVar(&c.DisableSwap, "disable-swap", c.DisableSwap, "skip the swap check")
+	fs.BoolVar(&c.Summary, "summary", c.Summary, "print a key=value line with every checked value after each successful poll, e.g. load=1.2 mem=0.62 disk=0.40 net=0.10")
+	fs.BoolVar(&c.JSONSummary, "json-summary", c.JSONSummary, "with -once, check, -input-file, -stdin or -sample print a single JSON object with every value and check instead of alert lines")
+	fs.BoolVar(&c.ShowUsage, "show-usage", c.ShowUsage, "append the used percentage to the disk and network alerts")
+	fs.StringVar(&c.DiskUnit, "disk-unit", c.DiskUnit, "unit for free disk space: mb, gb or auto")
+	fs.StringVar(&c.Units, "units", c.Units, "byte units in messages: legacy keeps the old Mb/Gb/Tb labels for 1024-based sizes, binary prints MiB/GiB/TiB, si prints MB/GB/TB for 1000-based sizes; network bandwidth is always in Mbit/s")
+	fs.StringVar(&c.ResponseFormat, "response-format", c.ResponseFormat, "format of the stats response: csv or json")
+	fs.StringVar(&c.Delimiter, "delimiter", c.Delimiter, "CSV field delimiter: \",\", \";\", tab or auto")
+	fs.StringVar(&c.Decimal, "decimal", c.Decimal, "decimal separator in CSV numbers: \".\" or \",\" (the latter needs a different -delimiter)")
+	fs.StringVar(&c.Thousands, "thousands", c.Thousands, "digit grouping separator to strip from CSV numbers, e.g. 8,589,934,592: \",\", \".\", \"'\" or space (disabled if empty)")
+	fs.BoolVar(&c.AverageRows, "average-rows", c.AverageRows, "average all data rows of the response instead of using only the first one")
+	fs.StringVar(&c.AuthToken, "auth-token", c.AuthToken, "bearer token for the stats endpoint (default $STATS_TOKEN)")
+	fs.StringVar(&c.User, "user", c.User, "basic auth user for the stats endpoint")
+	fs.StringVar(&c.Password, "password", c.Password, "basic auth password for the stats endpoint")
+	fs.Var(&c.Headers, "header", "extra header for the stats request as \"Key: Value\"; may be repeated")
+	fs.StringVar(&c.CACert, "ca-cert", c.CACert, "PEM file with a CA certificate to trust for stats requests, in addition to the system ones")
+	fs.StringVar(&c.ClientCert, "client-cert", c.ClientCert, "PEM file with a client certificate for mutual TLS with the stats servers (requires -client-key)")
+	fs.StringVar(&c.ClientKey, "client-key", c.ClientKey, "PEM file with the private key for -client-cert")
+	fs.BoolVar(&c.Insecure, "insecure", c.Insecure, "skip TLS certificate verification of the stats servers (for testing only)")
+	fs.IntVar(&c.Retries, "retries", c.Retries, "retry a failed fetch this many times within a single poll")
+	fs.DurationVar((*time.Duration)(&c.RetryBaseDelay), "retry-base-delay", time.Duration(c.RetryBaseDelay), "delay before the first retry, doubled on each next one")
+	fs.StringVar(&c.ContentTypes, "content-types", c.ContentTypes, "comma-separated Content-Type values accepted from the stats endpoint, or any (default depends on -response-format)")
+	fs.Int64Var(&c.MaxBodySize, "max-body-size", c.MaxBodySize, "give up on a stats response larger than this many bytes")
+	fs.StringVar(&c.MetricsAddr, "metrics-addr", c.MetricsAddr, "serve Prometheus metrics on this address, e.g. :9100 (disabled if empty)")
+	fs.StringVar(&c.StatsdAddr, "statsd-addr", c.StatsdAddr, "send metrics as StatsD gauges over UDP to this host:port after every poll")
+	fs.StringVar(&c.StatsdPrefix, "statsd-prefix", c.StatsdPrefix, "prefix for StatsD metric names, e.g. servers.monitor")
+	fs.StringVar(&c.OTLPEndpoint, "otlp-endpoint", c.OTLPEndpoint, "export metrics over OTLP/HTTP to this collector, e.g. http://localhost:4318")
+	fs.StringVar(&c.HealthAddr, "health-addr", c.HealthAddr, "serve /healthz and /status on this address, e.g. :8080 (disabled if empty)")
+	fs.DurationVar((*time.Duration)(&c.HealthMaxAge), "health-max-age", time.Duration(c.HealthMaxAge), "report unhealthy if a server had no successful poll for this long (0 means 3x -interval)")
+	fs.StringVar(&c.SlackWebhook, "slack-webhook", c.SlackWebhook, "also post alerts to this Slack incoming webhook URL")
+	fs.StringVar(&c.WebhookURL, "webhook-url", c.WebhookURL, "also post every alert as a JSON object to this URL")
+}
+
+// envName возвращает имя переменной окружения для флага: -load-limit
+// соответствует STATS_LOAD_LIMIT.
+func envName(flagName string) string {
+	return "STATS_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+const envUsage = `
+Every flag can also be set through an environment variable named STATS_ plus
+the flag name in upper case with dashes replaced by underscores, e.g. STATS_URL,
+STATS_INTERVAL, STATS_LOAD_LIMIT.
+
+Precedence, from highest to lowest: command-line flags, environment variables,
+the -config file, built-in defaults.
+`
+
+const commandUsage = `Commands:
+  check  poll every server once and exit: 0 if healthy, 1 if a threshold was
+         breached, 2 if a poll failed; the same as -once, e.g. for a container
+         HEALTHCHECK
+  watch  poll until stopped; the default when no command is given
+
+`
+
+// cliFlags — флаги, которые управляют самим запуском и в config не входят.
+type cliFlags struct {
+	command    string // check, watch или пусто
+	configPath string
+	version    bool
+	once       bool
+	listChecks bool
+	service    string
+}
+
+// serviceName — имя службы Windows для -service.
+const serviceName = "stats-monitor"
+
+// loadConfig собирает итоговую конфигурацию из args, окружения и -config
+// файла. Вызывается при запуске и заново при перечитывании по SIGHUP.
+func loadConfig(fs *flag.FlagSet, args []string) (config, cliFlags, error) {
+	cfg := defaultConfig()
+	var cli cliFlags
+	// команда необязательна и идёт первой: без неё всё работает как раньше
+	if len(args) > 0 && (args[0] == "check" || args[0] == "watch") {
+		cli.command, args = args[0], args[1:]
+	}
+	registerFlags(fs, &cfg)
+	fs.StringVar(&cli.configPath, "config", "", "read settings from this JSON file; flags given explicitly override it")
+	fs.BoolVar(&cli.version, "version", false, "print version information and exit")
+	fs.BoolVar(&cli.listChecks, "list-checks", false, "print every check with its effective threshold and alert message, then exit")
+	fs.BoolVar(&cli.once, "once", false, "poll every server a single time, print alerts and exit: 0 if healthy, 1 if a threshold was breached, 2 if a poll failed")
+	fs.StringVar(&cli.service, "service", "", "Windows only: install, uninstall, start or stop the "+serviceName+" service; install saves the other flags for the service with file paths made absolute and requires -log-file, -slack-webhook or -webhook-url")
+	if err := fs.Parse(args); err != nil {
+		return cfg, cli, err
+	}
+	if cli.version {
+		return cfg, cli, nil
+	}
+	if fs.NArg() > 0 {
+		return cfg, cli, fmt.Errorf("unknown command %q: must be check or watch, before the flags", fs.Arg(0))
+	}
+
+	// флаги запуска тоже задаются через STATS_*, поэтому проверяются после окружения
+	if err := resolveConfig(fs, &cfg, cli.configPath, args); err != nil {
+		return cfg, cli, err
+	}
+	switch cli.service {
+	case "", "install", "uninstall", "start", "stop":
+	default:
+		return cfg, cli, fmt.Errorf("invalid -service %q: must be install, uninstall, start or stop", cli.service)
+	}
+	// STATS_TOKEN поддерживается наравне с STATS_AUTH_TOKEN
+	if cfg.AuthToken == "" && cfg.User == "" {
+		cfg.AuthToken = os.Getenv("STATS_TOKEN")
+	}
+	if len(cfg.URLs) == 0 && cfg.InputFile == "" && !cfg.Stdin && cfg.Sample == "" {
+		cfg.URLs = urlList{statsURL}
+	}
+	// у службы нет консоли: без файла или получателя оповещения потеряются
+	if cli.service == "install" && cfg.LogFile == "" && cfg.SlackWebhook == "" && cfg.WebhookURL == "" {
+		return cfg, cli, errors.New("invalid -service install: a service has no console, set -log-file, -slack-webhook or -webhook-url to keep the alerts")
+	}
+	switch {
+	case cli.command == "check":
+		cli.once = true
+	case cli.command == "watch" && (cli.once || cfg.InputFile != "" || cfg.Stdin || cfg.Sample != ""):
+		return cfg, cli, errors.New("invalid watch: cannot be combined with -once, -input-file, -stdin or -sample; use check for a single poll")
+	}
+	// сводка печатается только по итогам одного прохода
+	if cfg.JSONSummary && !cli.once && cfg.InputFile == "" && !cfg.Stdin && cfg.Sample == "" {
+		return cfg, cli, errors.New("invalid -json-summary: requires -once, check, -input-file, -stdin or -sample")
+	}
+	return cfg, cli, cfg.validate()
+}
+
+// resolveConfig дополняет уже разобранные флаги значениями из файла и
+// окружения так, чтобы соблюдался порядок приоритетов из envUsage.
+func resolveConfig(fs *flag.FlagSet, c *config, path string, args []string) error {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	if path == "" && !explicit["config"] {
+		path = os.Getenv(envName("config"))
+	}
+	if path != "" {
+		if err := loadConfigFile(fs, c, path, args, explicit); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		v, ok := os.LookupEnv(envName(f.Name))
+		if err != nil || !ok || explicit[f.Name] || f.Name == "config" {
+			return
+		}
+		// повторяемые флаги дописывают значения, список из файла заменяется
+		switch f.Name {
+		case "url":
+			c.URLs = nil
+		case "header":
+			c.Headers = nil
+		}
+		if setErr := f.Value.Set(v); setErr != nil {
+			err = fmt.Errorf("invalid $%s %q: %w", envName(f.Name), v, setErr)
+		}
+	})
+	return err
+}
+
+// loadConfigFile читает JSON-файл поверх встроенных значений и затем заново
+// разбирает args, чтобы явно заданные флаги имели приоритет над файлом.
+func loadConfigFile(fs *flag.FlagSet, c *config, path string, args []string, explicit map[string]bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+	fileCfg := defaultConfig()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&fileCfg); err != nil {
+		return fmt.Errorf("parse config %s: %w", path, err)
+	}
+	*c = fileCfg
+
+	// повторяемые флаги дописывают значения, поэтому список из файла
+	// заменяется целиком, если флаг задан в командной строке
+	if explicit["url"] {
+		c.URLs = nil
+	}
+	if explicit["header"] {
+		c.Headers = nil
+	}
+	return fs.Parse(args)
+}
+
+func (c config) thresholds() monitor.Thresholds {
+	return monitor.Thresholds{
+		LoadAvg:      c.LoadLimit,
+		MemUsage:     c.MemLimit,
+		DiskUsage:    c.DiskLimit,
+		NetworkUsage: c.NetLimit,
+		SwapUsage:    c.SwapLimit,
+		Temperature:  c.TempLimit,
+	}
+}
+
+// critThresholds — пороги уровня critical; ноль означает, что второго
+// уровня у метрики нет.
+func (c config) critThresholds() monitor.Thresholds {
+	return monitor.Thresholds{
+		LoadAvg:      c.LoadCrit,
+		MemUsage:     c.MemCrit,
+		DiskUsage:    c.DiskCrit,
+		NetworkUsage: c.NetCrit,
+		SwapUsage:    c.SwapCrit,
+		Temperature:  c.TempCrit,
+	}
+}
+
+// floorThresholds — нижние границы; ноль выключает проверку.
+func (c config) floorThresholds() monitor.Thresholds {
+	return monitor.Thresholds{
+		LoadAvg:      c.LoadFloor,
+		MemUsage:     c.MemFloor,
+		DiskUsage:    c.DiskFloor,
+		NetworkUsage: c.NetFloor,
+		SwapUsage:    c.SwapFloor,
+		Temperature:  c.TempFloor,
+	}
+}
+
+func (c config) disabledChecks() map[string]bool {
+	return map[string]bool{
+		monitor.MetricLoadAvg: c.DisableLoad,
+		monitor.MetricMemory:  c.DisableMem,
+		monitor.MetricDisk:    c.DisableDisk,
+		monitor.MetricNetwork: c.DisableNet,
+		monitor.MetricSwap:    c.DisableSwap,
+	}
+}
+
+// checks — настройки проверки ответа для пакета monitor.
+func (c config) checks() monitor.Checks {
+	return monitor.Checks{
+		Limits:    c.thresholds(),
+		Crit:      c.critThresholds(),
+		Floors:    c.floorThresholds(),
+		Disabled:  c.disabledChecks(),
+		Rules:     c.Rules,
+		ShowUsage: c.ShowUsage,
+		DiskUnit:  c.DiskUnit,
+		Units:     c.Units,
+	}
+}
+
+func (c config) csvFormat() monitor.CSVFormat {
+	delim, _ := parseDelimiter(c.Delimiter)
+	thousands := c.Thousands
+	if thousands == "space" {
+		thousands = " "
+	}
+	return monitor.CSVFormat{Delim: delim, Decimal: c.Decimal, Thousands: thousands}
+}
+
+func (c config) request() monitor.Request {
+	headers := http.Header{}
+	for _, h := range c.Headers {
+		key, value, _ := parseHeader(h)
+		headers.Add(key, value)
+	}
+	return monitor.Request{
+		Token:          c.AuthToken,
+		User:           c.User,
+		Password:       c.Password,
+		Headers:        headers,
+		Retries:        c.Retries,
+		RetryBaseDelay: time.Duration(c.RetryBaseDelay),
+		MaxBodySize:    c.MaxBodySize,
+		Debug:          c.Debug,
+		ContentTypes:   c.contentTypes(),
+	}
+}
+
+func (c config) contentTypes() []string {
+	switch c.ContentTypes {
+	case "any":
+		return nil
+	case "":
+		if c.ResponseFormat == "json" {
+			return []string{"application/json"}
+		}
+		return []string{"text/plain", "text/csv", "application/csv"}
+	}
+	var types []string
+	for _, t := range strings.Split(c.ContentTypes, ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+func (c config) validate() error {
+	if err := validateThresholds(c.thresholds()); err != nil {
+		return err
+	}
+	crits := []struct {
+		name       string
+		warn, crit float64
+		fraction   bool
+	}{
+		{"load-crit", c.LoadLimit, c.LoadCrit, false},
+		{"mem-crit", c.MemLimit, c.MemCrit, true},
+		{"disk-crit", c.DiskLimit, c.DiskCrit, true},
+		{"net-crit", c.NetLimit, c.NetCrit, true},
+		{"swap-crit", c.SwapLimit, c.SwapCrit, true},
+		{"temp-crit", c.TempLimit, c.TempCrit, false},
+	}
+	for _, t := range crits {
+		switch {
+		case t.crit == 0:
+		case !(t.crit > 0) || t.fraction && t.crit > 1:
+			if t.fraction {
+				return fmt.Errorf("invalid -%s %s: must be a fraction between 0 and 1", t.name, fmtFloat(t.crit))
+			}
+			return fmt.Errorf("invalid -%s %s: must be non-negative", t.name, fmtFloat(t.crit))
+		case t.crit < t.warn:
+			return fmt.Errorf("invalid -%s %s: must not be below the warning threshold %s", t.name, fmtFloat(t.crit), fmtFloat(t.warn))
+		}
+	}
+	// нулевой -temp-limit выключает проверку температуры, и критический
+	// порог без него не сработал бы никогда
+	if c.TempCrit != 0 && c.TempLimit == 0 {
+		return errors.New("invalid -temp-crit: requires -temp-limit")
+	}
+	floors := []struct {
+		name        string
+		floor, warn float64
+		fraction    bool
+	}{
+		{"load-floor", c.LoadFloor, c.LoadLimit, false},
+		{"mem-floor", c.MemFloor, c.MemLimit, true},
+		{"disk-floor", c.DiskFloor, c.DiskLimit, true},
+		{"net-floor", c.NetFloor, c.NetLimit, true},
+		{"swap-floor", c.SwapFloor, c.SwapLimit, true},
+		{"temp-floor", c.TempFloor, c.TempLimit, false},
+	}
+	for _, t := range floors {
+		switch {
+		case t.floor == 0:
+		case !(t.floor > 0) || t.fraction && t.floor > 1:
+			if t.fraction {
+				return fmt.Errorf("invalid -%s %s: must be a fraction between 0 and 1", t.name, fmtFloat(t.floor))
+			}
+			return fmt.Errorf("invalid -%s %s: must be non-negative", t.name, fmtFloat(t.floor))
+		case t.warn > 0 && t.floor >= t.warn:
+			return fmt.Errorf("invalid -%s %s: must be below the warning threshold %s", t.name, fmtFloat(t.floor), fmtFloat(t.warn))
+		}
+	}
+	if err := validateRequest(c.request()); err != nil {
+		return err
+	}
+	for _, h := range c.Headers {
+		if _, _, err := parseHeader(h); err != nil {
+			return err
+		}
+	}
+	if c.Format != "text" && c.Format != "json" {
+		return fmt.Errorf("invalid -format %q: must be text or json", c.Format)
+	}
+	if c.ResponseFormat != "csv" && c.ResponseFormat != "json" {
+		return fmt.Errorf("invalid -response-format %q: must be csv or json", c.ResponseFormat)
+	}
+	if _, err := parseDelimiter(c.Delimiter); err != nil {
+		return err
+	}
+	if err := validateCSVFormat(c.csvFormat()); err != nil {
+		return err
+	}
+	if err := validateDiskUnit(c.DiskUnit); err != nil {
+		return err
+	}
+	switch c.Units {
+	case monitor.UnitsLegacy, monitor.UnitsBinary, monitor.UnitsSI:
+	default:
+		return fmt.Errorf("invalid -units %q: must be legacy, binary or si", c.Units)
+	}
+	if c.Interval <= 0 {
+		return fmt.Errorf("invalid -interval %s: must be positive", time.Duration(c.Interval))
+	}
+	if c.StartDelay < 0 {
+		return fmt.Errorf("invalid -start-delay %s: must not be negative", time.Duration(c.StartDelay))
+	}
+	if c.Jitter < 0 || c.Jitter >= 100 {
+		return fmt.Errorf("invalid -jitter %s: must be a percentage between 0 and 100", fmtFloat(c.Jitter))
+	}
+	if c.MaxRuntime < 0 {
+		return fmt.Errorf("invalid -max-runtime %s: must be non-negative", time.Duration(c.MaxRuntime))
+	}
+	if c.PollDeadline < 0 {
+		return fmt.Errorf("invalid -poll-deadline %s: must be non-negative", time.Duration(c.PollDeadline))
+	}
+	if c.MaxIdleConns < 0 {
+		return fmt.Errorf("invalid -max-idle-conns %d: must be non-negative", c.MaxIdleConns)
+	}
+	if c.IdleConnTimeout < 0 {
+		return fmt.Errorf("invalid -idle-conn-timeout %s: must be non-negative", time.Duration(c.IdleConnTimeout))
+	}
+	if c.DNSServer != "" {
+		if _, err := dnsServerAddr(c.DNSServer); err != nil {
+			return err
+		}
+	}
+	if c.MaxRedirects < 0 {
+		return fmt.Errorf("invalid -max-redirects %d: must be non-negative", c.MaxRedirects)
+	}
+	if c.Journal && c.LogFile != "" {
+		return errors.New("invalid -journal: cannot be combined with -log-file")
+	}
+	if c.Summary && c.JSONSummary {
+		return errors.New("invalid -summary: cannot be combined with -json-summary")
+	}
+	if c.Summary && c.Format == "json" {
+		return errors.New("invalid -summary: cannot be combined with -format json")
+	}
+	if c.ReportEvery < 0 {
+		return fmt.Errorf("invalid -report-every %d: must be non-negative", c.ReportEvery)
+	}
+	if strings.TrimSpace(c.FetchErrorMessage) == "" {
+		return errors.New("invalid -fetch-error-message: must not be empty")
+	}
+	if c.ErrorThreshold < 1 {
+		return fmt.Errorf("invalid -error-threshold %d: must be at least 1", c.ErrorThreshold)
+	}
+	if c.Quiet && (c.Verbose || c.Debug) {
+		return errors.New("invalid -quiet: cannot be combined with -verbose or -debug")
+	}
+	if c.Hysteresis < 0 || c.Hysteresis >= 100 {
+		return fmt.Errorf("invalid -hysteresis %s: must be a percentage between 0 and 100", fmtFloat(c.Hysteresis))
+	}
+	if c.Hysteresis > 0 && !c.Debounce {
+		return errors.New("invalid -hysteresis: requires -debounce")
+	}
+	if c.RemindEvery < 0 {
+		return fmt.Errorf("invalid -remind-every %s: must be non-negative", time.Duration(c.RemindEvery))
+	}
+	if c.RemindEvery > 0 && !c.Debounce {
+		return errors.New("invalid -remind-every: requires -debounce")
+	}
+	if !(c.EWMAAlpha >= 0 && c.EWMAAlpha <= 1) {
+		return fmt.Errorf("invalid -ewma-alpha %s: must be between 0 and 1", fmtFloat(c.EWMAAlpha))
+	}
+	if c.LoadWindow < 1 {
+		return fmt.Errorf("invalid -load-window %d: must be at least 1", c.LoadWindow)
+	}
+	if c.AlertConsecutive < 1 {
+		return fmt.Errorf("invalid -alert-consecutive %d: must be at least 1", c.AlertConsecutive)
+	}
+	if c.AlertCooldown < 0 {
+		return fmt.Errorf("invalid -alert-cooldown %s: must not be negative", time.Duration(c.AlertCooldown))
+	}
+	if c.ClientCert != "" && c.ClientKey == "" {
+		return errors.New("invalid -client-cert: requires -client-key")
+	}
+	if c.ClientKey != "" && c.ClientCert == "" {
+		return errors.New("invalid -client-key: requires -client-cert")
+	}
+	if c.ContentTypes != "" && len(c.contentTypes()) == 0 && c.ContentTypes != "any" {
+		return fmt.Errorf("invalid -content-types %q: list is empty", c.ContentTypes)
+	}
+	if c.HealthMaxAge < 0 {
+		return fmt.Errorf("invalid -health-max-age %s: must not be negative", time.Duration(c.HealthMaxAge))
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("invalid -timeout %s: must be positive", time.Duration(c.Timeout))
+	}
+	if c.Stdin && c.InputFile != "" {
+		return errors.New("invalid -stdin: cannot be combined with -input-file")
+	}
+	if c.Stdin && len(c.URLs) > 0 {
+		return errors.New("invalid -stdin: cannot be combined with -url")
+	}
+	if c.InputFile != "" && len(c.URLs) > 0 {
+		return errors.New("invalid -input-file: cannot be combined with -url")
+	}
+	if c.Sample != "" && (c.Stdin || c.InputFile != "" || len(c.URLs) > 0) {
+		return errors.New("invalid -sample: cannot be combined with -url, -input-file or -stdin")
+	}
+	for _, raw := range c.URLs {
+		if socket, _, ok := splitUnixURL(raw); ok {
+			if socket == "" {
+				return fmt.Errorf("invalid -url %q: missing socket path", raw)
+			}
+			continue
+		}
+		if _, err := parseHTTPURL("url", raw); err != nil {
+			return err
+		}
+	}
+	if err := monitor.ValidateRules(c.Rules); err != nil {
+		return err
+	}
+	if c.OTLPEndpoint != "" {
+		if _, err := parseHTTPURL("otlp-endpoint", c.OTLPEndpoint); err != nil {
+			return err
+		}
+	}
+	if c.SlackWebhook != "" {
+		if _, err := parseHTTPURL("slack-webhook", c.SlackWebhook); err != nil {
+			return err
+		}
+	}
+	if c.WebhookURL != "" {
+		if _, err := parseHTTPURL("webhook-url", c.WebhookURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateThresholds(t monitor.Thresholds) error {
+	if !(t.LoadAvg >= 0) {
+		return fmt.Errorf("invalid -load-limit %s: must be non-negative", fmtFloat(t.LoadAvg))
+	}
+	if !(t.Temperature >= 0) {
+		return fmt.Errorf("invalid -temp-limit %s: must be non-negative", fmtFloat(t.Temperature))
+	}
+	fractions := []struct {
+		name  string
+		value float64
+	}{
+		{"mem-limit", t.MemUsage},
+		{"disk-limit", t.DiskUsage},
+		{"net-limit", t.NetworkUsage},
+		{"swap-limit", t.SwapUsage},
+	}
+	for _, f := range fractions {
+		if !(f.value >= 0 && f.value <= 1) {
+			return fmt.Errorf("invalid -%s %s: must be a fraction between 0 and 1", f.name, fmtFloat(f.value))
+		}
+	}
+	return nil
+}
+
+func parseHTTPURL(name, raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -%s %q: %w", name, raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid -%s %q: scheme must be http or https", name, raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid -%s %q: missing host", name, raw)
+	}
+	return u, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"sync"
+)
+
+// ansiStripper убирает цвет: в файл пишется тот же текст, что и в терминал.
+var ansiStripper = strings.NewReplacer(ansiRed, "", ansiGreen, "", ansiReset, "")
+
+// logFile дописывает оповещения в файл и умеет переоткрыть его после того,
+// как внешний logrotate переименовал старый.
+type logFile struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+func openLogFile(path string) (*logFile, error) {
+	l := &logFile{path: path}
+	if err := l.reopen(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *logFile) reopen() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f != nil {
+		l.f.Close()
+	}
+	l.f = f
+	return nil
+}
+
+func (l *logFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.f.WriteString(ansiStripper.Replace(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (l *logFile) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
@@ -1,177 +1,251 @@
 package main
 
 import (
-	"bufio"
-	"errors"
+	"context"
+	"flag"
 	"fmt"
 	"io"
-	"net/http"
-	"strconv"
+	"log"
+	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
-)
 
-const (
-	statsURL          = "http://srv.msk01.gigacorp.local/_stats"
-	pollInterval      = 5 * time.Second
-	httpTimeout       = 3 * time.Second
-	errorThreshold    = 3
-	loadAvgLimit      = 30.0
-	memUsageLimit     = 0.80
-	diskUsageLimit    = 0.90
-	networkUsageLimit = 0.90
+	"github.com/leonidSpiri/go-homework/monitor"
 )
 
 func main() {
-	client := &http.Client{Timeout: httpTimeout}
-	errStreak := 0
-	ticker := time.NewTicker(pollInterval)
-	defer ticker.Stop()
-
-	for {
-		if err := pollOnce(client, statsURL); err != nil {
-			errStreak++
-			if errStreak >= errorThreshold {
-				fmt.Println("Unable to fetch server statistic.")
-				errStreak = 0
-			}
-		} else {
-			errStreak = 0
-		}
-		<-ticker.C
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [check|watch] [flags]\n\n", os.Args[0])
+		fmt.Fprint(flag.CommandLine.Output(), commandUsage)
+		fmt.Fprintln(flag.CommandLine.Output(), "Flags:")
+		flag.PrintDefaults()
+		fmt.Fprint(flag.CommandLine.Output(), envUsage)
+	}
+	cfg, cli, err := loadConfig(flag.CommandLine, os.Args[1:])
+	if cli.version {
+		fmt.Println(versionString())
+		return
 	}
-}
-
-func pollOnce(client *http.Client, url string) error {
-	resp, err := client.Get(url)
 	if err != nil {
-		return err
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		io.Copy(io.Discard, resp.Body)
-		return fmt.Errorf("unexpected status: %s", resp.Status)
+	if cli.service != "" {
+		args, err := serviceArgs(flag.CommandLine, os.Args[1:])
+		if err == nil {
+			err = controlService(cli.service, args)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
+	}
+	if cli.listChecks {
+		if err := listChecks(os.Stdout, cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
 	}
+	once := cli.once
+	if cfg.Timeout > cfg.Interval && !cfg.Quiet {
+		fmt.Fprintf(os.Stderr, "warning: -timeout %s exceeds -interval %s, slow polls will delay the next ones\n",
+			time.Duration(cfg.Timeout), time.Duration(cfg.Interval))
+	}
+
+	targets, hosts, shown := serverTargets(cfg.URLs)
+
+	opts := newMonitorOptions(cfg, len(targets) > 1)
 
-	body, err := readAllTrim(resp.Body)
+	opts.parser, err = monitor.NewParser(cfg.ResponseFormat, cfg.csvFormat(), cfg.AverageRows)
 	if err != nil {
-		return err
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
-
-	values, err := parseCSVNumbers(body)
+	// с одним сервером текстовый вывод остаётся прежним, без префикса
+	opts.formatter, err = newFormatter(cfg.Format, len(targets) > 1, colorEnabled(os.Stdout, cfg.NoColor))
 	if err != nil {
-		return err
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	// в JSON время уже есть в поле timestamp, префикс сломал бы разбор строк
+	logFlags := log.LstdFlags
+	if cfg.NoTimestamp {
+		logFlags = 0
+	}
+	log.SetFlags(logFlags)
+	if cfg.Format == "json" {
+		logFlags = 0
+	}
+	var alertOut io.Writer = os.Stdout
+	var alertLog *logFile
+	if cfg.LogFile != "" {
+		var err error
+		if alertLog, err = openLogFile(cfg.LogFile); err != nil {
+			fmt.Fprintf(os.Stderr, "open -log-file: %v\n", err)
+			os.Exit(2)
+		}
+		defer alertLog.Close()
+		alertOut = io.MultiWriter(os.Stdout, alertLog)
+	}
+	opts.out = log.New(alertOut, "", logFlags)
+	if cfg.Journal {
+		if !underSystemd() {
+			if !cfg.Quiet {
+				fmt.Fprintln(os.Stderr, "warning: -journal: not running under systemd, writing alerts to stdout")
+			}
+		} else if opts.journal, err = newJournalSink(); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: -journal: %v, writing alerts to stdout\n", err)
+		}
 	}
-	if len(values) != 7 {
-		return fmt.Errorf("invalid fields count: got %d, want 7", len(values))
+	opts.errs = log.Default()
+	// с -quiet остаются только оповещения и ошибки опроса
+	if cfg.Quiet {
+		opts.errs = log.New(os.Stderr, "", log.Flags())
+		log.SetOutput(io.Discard)
 	}
 
-	loadAvg := values[0]
-	memTotal := uint64(values[1])
-	memUsed := uint64(values[2])
-	diskTotal := uint64(values[3])
-	diskUsed := uint64(values[4])
-	netCapBps := uint64(values[5])
-	netUsedBps := uint64(values[6])
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// под диспетчером служб Windows ctx отменяется и при остановке службы
+	ctx, serviceDone := serviceContext(ctx)
+	defer serviceDone()
 
-	// 1) Load Average
-	if loadAvg > loadAvgLimit {
-		fmt.Printf("Load Average is too high: %s\n", fmtFloat(loadAvg))
+	if cfg.Insecure {
+		// и с -quiet: отключённая проверка TLS не должна пройти незаметно
+		fmt.Fprintln(os.Stderr, "warning: -insecure is set, TLS certificates are NOT verified; never use it in production")
+	}
+	client, sinkClient, err := newClients(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
 
-	// 2) Memory
-	if memTotal > 0 {
-		memUsage := float64(memUsed) / float64(memTotal)
-		if memUsage > memUsageLimit {
-			percent := int64(round(100.0 * memUsage))
-			fmt.Printf("Memory usage too high: %d%%\n", percent)
-		}
+	// -sample только показывает результат проверки: без опросов и рассылки
+	dryRun := cfg.Sample != ""
+	if cfg.InputFile != "" || cfg.Stdin || dryRun {
+		once = true
+	}
+	// по SIGHUP файл переоткрывается, как принято для logrotate, а настройки
+	// проверки перечитываются из -config
+	reload := !once && (cli.configPath != "" || os.Getenv(envName("config")) != "")
+	if reload {
+		opts.live = &atomic.Pointer[evalSettings]{}
+		opts.live.Store(newEvalSettings(cfg))
+	}
+	if alertLog != nil || reload {
+		handleSIGHUP(alertLog, opts.live, reload)
 	}
 
-	// 3) Disk
-	if diskTotal > 0 {
-		diskUsage := float64(diskUsed) / float64(diskTotal)
-		if diskUsage > diskUsageLimit {
-			freeBytes := int64(diskTotal) - int64(diskUsed)
-			if freeBytes < 0 {
-				freeBytes = 0
-			}
-			freeMB := freeBytes / (1024 * 1024) // Мб (бинарные)
-			fmt.Printf("Free disk space is too low: %d Mb left\n", freeMB)
+	if cfg.MetricsAddr != "" && !once {
+		opts.gauges = newGaugeSet()
+		if err := serveMetrics(ctx, cfg.MetricsAddr, opts.gauges); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
 		}
 	}
 
-	// 4) Network
-	if netCapBps > 0 {
-		netUsage := float64(netUsedBps) / float64(netCapBps)
-		if netUsage > networkUsageLimit {
-			freeBps := int64(netCapBps) - int64(netUsedBps)
-			if freeBps < 0 {
-				freeBps = 0
-			}
-			// свободная полоса в мегабитах/сек (SI): Bps * 8 / 1_000_000
-			freeMbit := float64(freeBps) / 1_000_000.0
-			fmt.Printf("Network bandwidth usage high: %s Mbit/s available\n", fmtFloat(freeMbit))
+	if cfg.StatsdAddr != "" && !dryRun {
+		if opts.statsd, err = newStatsdSink(cfg.StatsdAddr, cfg.StatsdPrefix); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
 		}
 	}
 
-	return nil
-}
+	if cfg.HealthAddr != "" && !once {
+		maxAge := time.Duration(cfg.HealthMaxAge)
+		if maxAge == 0 {
+			maxAge = 3 * opts.interval
+		}
+		opts.status = newPollStatus(opts.clock, maxAge, hosts)
+		if err := serveStatus(ctx, cfg.HealthAddr, opts.status); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
 
-func readAllTrim(r io.Reader) (string, error) {
-	var sb strings.Builder
-	sc := bufio.NewScanner(r)
-	const maxBuffSize = 1 << 20
-	buf := make([]byte, 0, 64*1024)
-	sc.Buffer(buf, maxBuffSize)
-
-	for sc.Scan() {
-		line := strings.TrimSpace(sc.Text())
-		if line != "" {
-			if sb.Len() > 0 {
-				sb.WriteByte('\n')
-			}
-			sb.WriteString(line)
+	if cfg.OTLPEndpoint != "" && !dryRun {
+		if opts.otlp, err = newOTLPSink(cfg.OTLPEndpoint, opts.interval); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
 		}
 	}
-	if err := sc.Err(); err != nil {
-		return "", err
+	if cfg.SlackWebhook != "" && !dryRun {
+		opts.webhooks = append(opts.webhooks, newWebhook("slack webhook", sinkClient, cfg.SlackWebhook, encodeSlack))
+	}
+	if cfg.WebhookURL != "" && !dryRun {
+		opts.webhooks = append(opts.webhooks, newWebhook("webhook", sinkClient, cfg.WebhookURL, encodeAlertJSON))
 	}
-	return strings.TrimSpace(sb.String()), nil
-}
 
-func parseCSVNumbers(s string) ([]float64, error) {
-	line := s
-	if idx := strings.IndexByte(s, '\n'); idx >= 0 {
-		line = s[:idx]
-	}
-	parts := strings.Split(strings.TrimSpace(line), ",")
-	var out []float64
-	for _, p := range parts {
-		p = strings.TrimSpace(p)
-		if p == "" {
-			continue
+	// -stdin, -input-file и -sample проверяют один сохранённый ответ, как -once
+	var poll func(target, server string) monitor.Result
+	switch {
+	case dryRun:
+		targets, hosts = []string{"sample"}, []string{"sample"}
+		poll = func(_, server string) monitor.Result {
+			return opts.newMonitor(client, "", server).Check(ctx, strings.TrimSpace(cfg.Sample))
 		}
-		v, err := strconv.ParseFloat(p, 64)
-		if err != nil {
-			return nil, fmt.Errorf("parse number %q: %w", p, err)
+	case cfg.Stdin:
+		targets, hosts = []string{"-"}, []string{"stdin"}
+		poll = func(_, server string) monitor.Result {
+			return opts.newMonitor(client, "", server).CheckReader(os.Stdin)
+		}
+	case cfg.InputFile != "":
+		targets, hosts = []string{cfg.InputFile}, []string{cfg.InputFile}
+		poll = func(path, server string) monitor.Result {
+			f, err := os.Open(path)
+			if err != nil {
+				return monitor.Result{Server: server, Err: err}
+			}
+			defer f.Close()
+			return opts.newMonitor(client, "", server).CheckReader(f)
+		}
+	case once:
+		poll = func(target, server string) monitor.Result {
+			return opts.newMonitor(client, target, server).Poll(ctx)
 		}
-		out = append(out, v)
-	}
-	if len(out) == 0 {
-		return nil, errors.New("no numbers parsed")
 	}
-	return out, nil
-}
-
-func fmtFloat(v float64) string {
-	return strconv.FormatFloat(v, 'f', -1, 64)
-}
-
-func round(v float64) float64 {
-	if v >= 0 {
-		return float64(int64(v + 0.5))
+	if poll != nil {
+		code := runOnce(targets, hosts, opts, poll)
+		opts.closeWebhooks()
+		stop()
+		os.Exit(code)
+	}
+
+	log.Printf("starting monitor: url=%s interval=%s timeout=%s checks: %s",
+		strings.Join(shown, ","), opts.interval, client.Timeout, thresholdSummary(cfg))
+
+	// по истечении -max-runtime опросы завершаются так же, как по сигналу
+	runCtx := ctx
+	if maxRuntime := time.Duration(cfg.MaxRuntime); maxRuntime > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, maxRuntime)
+		defer cancel()
+	}
+
+	var wg sync.WaitGroup
+	counts := make([]pollCounts, len(targets))
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target, server string) {
+			defer wg.Done()
+			counts[i] = watch(runCtx, client, target, server, opts)
+		}(i, target, hosts[i])
+	}
+	wg.Wait()
+	opts.closeWebhooks()
+	// итог по -max-runtime печатается и с -quiet
+	if ctx.Err() == nil && runCtx.Err() != nil {
+		opts.errs.Printf("max runtime %s reached", time.Duration(cfg.MaxRuntime))
+		for i, server := range hosts {
+			opts.errs.Printf("%s: %s", server, counts[i])
+		}
 	}
-	return float64(int64(v - 0.5))
+	log.Println("shutting down")
 }
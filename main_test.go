@@ -0,0 +1,519 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/leonidSpiri/go-homework/monitor"
+	"github.com/leonidSpiri/go-homework/monitor/monitortest"
+)
+
+func testOptions() monitorOptions {
+	parser, _ := monitor.NewParser("csv", monitor.CSVFormat{Delim: ",", Decimal: "."}, false)
+	return monitorOptions{
+		checks: monitor.Checks{
+			Limits: monitor.Thresholds{
+				LoadAvg:      loadAvgLimit,
+				MemUsage:     memUsageLimit,
+				DiskUsage:    diskUsageLimit,
+				NetworkUsage: networkUsageLimit,
+			},
+			DiskUnit: monitor.DiskUnitMB,
+		},
+		parser: parser,
+		errs:   log.New(io.Discard, "", 0),
+		clock:  monitor.RealClock{},
+	}
+}
+
+func TestMonitorDebounce(t *testing.T) {
+	bodies := []string{
+		"1,100,90,100,10,100,10",
+		"1,100,95,100,10,100,10",
+		"1,100,25,100,10,100,10",
+		"1,100,25,100,10,100,10",
+	}
+	var (
+		mu       sync.Mutex
+		requests int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write([]byte(bodies[min(requests, len(bodies)-1)]))
+		requests++
+	}))
+	defer srv.Close()
+
+	var out bytes.Buffer
+	clk := monitortest.NewClock(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	opts := testOptions()
+	opts.interval = time.Second
+	opts.errThreshold = errorThreshold
+	opts.debounce = true
+	opts.clock = clk
+	opts.formatter = textFormatter{}
+	opts.out = log.New(&out, "", 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watch(ctx, srv.Client(), srv.URL, "test", opts)
+	}()
+	// тик принимается только после завершения очередного опроса
+	for range bodies {
+		clk.Tick()
+	}
+	cancel()
+	<-done
+
+	want := "Memory usage too high: 90%\nMemory usage back to normal: 25%\n"
+	if got := out.String(); got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, strings.TrimSpace(want))
+	}
+}
+
+func TestGaugeSetServeHTTP(t *testing.T) {
+	g := newGaugeSet()
+	alerts := []monitor.Alert{
+		{Metric: monitor.MetricMemory, Status: monitor.StatusFiring, Value: 0.9},
+		{Metric: monitor.MetricDisk, Status: monitor.StatusOK, Value: 0.5},
+		{Metric: monitor.MetricMemoryLow, Status: monitor.StatusOK, Value: 0.9},
+	}
+	g.update(`web"1`, alerts, alerts[:1])
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	want := `# HELP memory_usage_ratio Used memory as a fraction of total memory.
+# TYPE memory_usage_ratio gauge
+memory_usage_ratio{server="web\"1"} 0.9
+# HELP disk_usage_ratio Used disk space as a fraction of total disk space.
+# TYPE disk_usage_ratio gauge
+disk_usage_ratio{server="web\"1"} 0.5
+# HELP memory_usage_alerting Whether memory_usage is alerting (1) or not (0).
+# TYPE memory_usage_alerting gauge
+memory_usage_alerting{server="web\"1"} 1
+# HELP disk_usage_alerting Whether disk_usage is alerting (1) or not (0).
+# TYPE disk_usage_alerting gauge
+disk_usage_alerting{server="web\"1"} 0
+# HELP memory_usage_low_alerting Whether memory_usage is alerting for being below its floor (1) or not (0).
+# TYPE memory_usage_low_alerting gauge
+memory_usage_low_alerting{server="web\"1"} 0
+`
+	if got := rec.Body.String(); got != want {
+		t.Errorf("metrics:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// Gauge тревоги и /status следуют -alert-consecutive и мёртвой зоне -hysteresis.
+func TestWatchAlertingGauge(t *testing.T) {
+	bodies := make(chan string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case body := <-bodies:
+			w.Write([]byte(body))
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	clk := monitortest.NewClock(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	opts := testOptions()
+	opts.interval = time.Second
+	opts.errThreshold = errorThreshold
+	opts.debounce = true
+	opts.consecutive = 2
+	opts.hysteresis = 0.1
+	opts.clock = clk
+	opts.formatter = textFormatter{}
+	opts.out = log.New(io.Discard, "", 0)
+	opts.gauges = newGaugeSet()
+	opts.status = newPollStatus(clk, time.Minute, []string{"test"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watch(ctx, srv.Client(), srv.URL, "test", opts)
+	}()
+	steps := []struct {
+		mem  string
+		want float64
+	}{
+		{"90", 0}, // первое превышение ещё не тревога
+		{"90", 1},
+		{"75", 1}, // ниже порога 80%, но в мёртвой зоне до 72%
+		{"25", 0},
+	}
+	for i, step := range steps {
+		bodies <- "1,100," + step.mem + ",100,10,100,10"
+		// тик принимается после завершения опроса, следующий ждёт тела ответа
+		clk.Tick()
+		opts.gauges.mu.Lock()
+		got := opts.gauges.alerting[monitor.MetricMemory]["test"]
+		opts.gauges.mu.Unlock()
+		if got != step.want {
+			t.Errorf("step %d: memory_usage_alerting = %v, want %v", i+1, got, step.want)
+		}
+		opts.status.mu.Lock()
+		status := ""
+		for _, a := range opts.status.results["test"] {
+			if a.Metric == monitor.MetricMemory {
+				status = a.Status
+			}
+		}
+		opts.status.mu.Unlock()
+		if want := map[float64]string{0: monitor.StatusOK, 1: monitor.StatusFiring}[step.want]; status != want {
+			t.Errorf("step %d: /status memory_usage %s, want %s", i+1, status, want)
+		}
+	}
+	cancel()
+	<-done
+}
+
+func TestAlertStateSeverity(t *testing.T) {
+	state := alertState{}
+	steps := []struct {
+		status, severity string
+		want             string
+	}{
+		{monitor.StatusFiring, monitor.SeverityWarning, monitor.StatusFiring},
+		{monitor.StatusFiring, monitor.SeverityWarning, ""},
+		{monitor.StatusFiring, monitor.SeverityCritical, monitor.StatusFiring},
+		{monitor.StatusFiring, monitor.SeverityWarning, monitor.StatusFiring},
+		{monitor.StatusOK, "", monitor.StatusResolved},
+	}
+	for i, s := range steps {
+		got := ""
+		if out := state.update([]monitor.Alert{{Metric: monitor.MetricMemory, Status: s.status, Severity: s.severity}}, 0); len(out) > 0 {
+			got = out[0].Status
+		}
+		if got != s.want {
+			t.Errorf("step %d: status %q, want %q", i+1, got, s.want)
+		}
+	}
+}
+
+func TestAlertReminder(t *testing.T) {
+	clk := monitortest.NewClock(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	r := newAlertReminder(10 * time.Minute)
+	warn := monitor.Alert{Metric: monitor.MetricMemory, Status: monitor.StatusFiring, Severity: monitor.SeverityWarning, Message: "Memory usage too high: 90%"}
+	crit := warn
+	crit.Severity = monitor.SeverityCritical
+	ok := monitor.Alert{Metric: monitor.MetricMemory, Status: monitor.StatusOK}
+	resolved := ok
+	resolved.Status = monitor.StatusResolved
+
+	steps := []struct {
+		after   time.Duration
+		alert   monitor.Alert
+		changed bool // alertState.update сообщил об изменении на этом опросе
+		want    string
+	}{
+		{0, warn, true, ""}, // о начале тревоги уже сообщено
+		{5 * time.Minute, warn, false, ""},
+		{5 * time.Minute, warn, false, "Memory usage too high: 90% (still firing, 10m)"},
+		{5 * time.Minute, warn, false, ""},
+		{2 * time.Minute, crit, true, ""}, // смена уровня не сбрасывает начало тревоги
+		{10 * time.Minute, crit, false, "Memory usage too high: 90% (still firing, 27m)"},
+		{3 * time.Minute, resolved, true, ""},
+		{time.Minute, warn, true, ""},
+		{10 * time.Minute, warn, false, "Memory usage too high: 90% (still firing, 10m)"},
+	}
+	for i, s := range steps {
+		clk.Advance(s.after)
+		var changed []monitor.Alert
+		if s.changed {
+			changed = []monitor.Alert{s.alert}
+		}
+		alert := s.alert
+		if alert.Status == monitor.StatusResolved {
+			alert = ok
+		}
+		got := ""
+		if out := r.remind([]monitor.Alert{alert}, changed, clk.Now()); len(out) > 0 {
+			got = out[0].Message
+		}
+		if got != s.want {
+			t.Errorf("step %d: reminder %q, want %q", i+1, got, s.want)
+		}
+	}
+}
+
+func TestFmtDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		45 * time.Second:                "45s",
+		12*time.Minute + 20*time.Second: "12m",
+		time.Hour + 5*time.Minute:       "1h5m",
+		2 * time.Hour:                   "2h",
+	} {
+		if got := fmtDuration(d); got != want {
+			t.Errorf("fmtDuration(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestNewClients(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Insecure = true
+	cfg.DNSServer = "127.0.0.1"
+	cfg.MaxRedirects = 0
+	stats, sinks, err := newClients(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr := stats.Transport.(*http.Transport); tr.TLSClientConfig == nil || !tr.TLSClientConfig.InsecureSkipVerify {
+		t.Error("stats client: -insecure not applied")
+	}
+	// получатели оповещений всегда проверяют сертификаты и ходят через системный DNS,
+	// переадресацию -max-redirects не ограничивает
+	if sinks.Transport != nil {
+		t.Errorf("sink client transport = %T, want the default one", sinks.Transport)
+	}
+	if stats.CheckRedirect == nil || sinks.CheckRedirect != nil {
+		t.Error("-max-redirects must apply to the stats client only")
+	}
+}
+
+func TestRedirectPolicy(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) { http.Redirect(w, r, "/b", http.StatusFound) })
+	mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) { http.Redirect(w, r, "/c", http.StatusFound) })
+	mux.HandleFunc("/c", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		max    int
+		status int // 0 — ошибка
+	}{
+		{0, http.StatusFound},
+		{1, 0},
+		{2, http.StatusOK},
+	} {
+		c := &http.Client{CheckRedirect: redirectPolicy(tt.max)}
+		resp, err := c.Get(srv.URL + "/a")
+		status := 0
+		if err == nil {
+			resp.Body.Close()
+			status = resp.StatusCode
+		}
+		if status != tt.status {
+			t.Errorf("max %d: status %d (err %v), want %d", tt.max, status, err, tt.status)
+		}
+	}
+}
+
+func TestMaxTargetsPerHost(t *testing.T) {
+	urls := []string{
+		"http://srv1/_stats", "http://srv1/_stats?node=2", "http://srv1/_stats?node=3",
+		"https://srv2/_stats", "unix:///run/agent.sock:/_stats",
+	}
+	if got := maxTargetsPerHost(urls); got != 3 {
+		t.Errorf("maxTargetsPerHost = %d, want 3", got)
+	}
+}
+
+func TestOTLPMetricsURL(t *testing.T) {
+	for endpoint, want := range map[string]string{
+		"http://localhost:4318":              "http://localhost:4318/v1/metrics",
+		"http://localhost:4318/":             "http://localhost:4318/v1/metrics",
+		"https://otel.example.com/custom/ep": "https://otel.example.com/custom/ep",
+	} {
+		if got := otlpMetricsURL(endpoint); got != want {
+			t.Errorf("otlpMetricsURL(%q) = %q, want %q", endpoint, got, want)
+		}
+	}
+}
+
+func TestOTLPSink(t *testing.T) {
+	requests := make(chan *colmetricpb.ExportMetricsServiceRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/metrics" {
+			t.Errorf("path %q, want /v1/metrics", r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req colmetricpb.ExportMetricsServiceRequest
+		if err := proto.Unmarshal(body, &req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		requests <- &req
+	}))
+	defer srv.Close()
+
+	sink, err := newOTLPSink(srv.URL, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.send([]monitor.Alert{
+		{Metric: monitor.MetricMemory, Server: "web1", Value: 0.9},
+		{Metric: monitor.MetricFetch, Server: "web1", Value: 1},
+	})
+	// выгрузка по таймеру не наступит, значения отправляет close
+	sink.close()
+	req := <-requests
+
+	rm := req.ResourceMetrics[0]
+	service := ""
+	for _, a := range rm.Resource.Attributes {
+		if a.Key == "service.name" {
+			service = a.Value.GetStringValue()
+		}
+	}
+	if service != otlpService {
+		t.Errorf("service.name = %q, want %q", service, otlpService)
+	}
+	sm := rm.ScopeMetrics[0]
+	if sm.Scope.Name != otlpScope {
+		t.Errorf("scope = %q, want %q", sm.Scope.Name, otlpScope)
+	}
+	if len(sm.Metrics) != 1 {
+		t.Fatalf("got %d metrics, want only memory_usage_ratio", len(sm.Metrics))
+	}
+	m := sm.Metrics[0]
+	points := m.GetGauge().GetDataPoints()
+	if m.Name != "memory_usage_ratio" || len(points) != 1 {
+		t.Fatalf("metric %s with %d points, want memory_usage_ratio gauge with 1 point", m.Name, len(points))
+	}
+	p := points[0]
+	if p.GetAsDouble() != 0.9 || len(p.Attributes) != 1 || p.Attributes[0].Key != "server" ||
+		p.Attributes[0].Value.GetStringValue() != "web1" {
+		t.Errorf("data point = %v, want 0.9 with server=web1", p)
+	}
+}
+
+func TestSplitUnixURL(t *testing.T) {
+	tests := []struct {
+		raw          string
+		socket, path string
+		ok           bool
+	}{
+		{"unix:///var/run/agent.sock:/_stats", "/var/run/agent.sock", "/_stats", true},
+		{"unix:///var/run/agent.sock", "/var/run/agent.sock", "/", true},
+		{"http://srv/_stats", "", "", false},
+	}
+	for _, tt := range tests {
+		socket, path, ok := splitUnixURL(tt.raw)
+		if socket != tt.socket || path != tt.path || ok != tt.ok {
+			t.Errorf("splitUnixURL(%q) = %q, %q, %v, want %q, %q, %v", tt.raw, socket, path, ok, tt.socket, tt.path, tt.ok)
+		}
+	}
+}
+
+func TestStatusAlert(t *testing.T) {
+	tests := []struct {
+		code           int
+		status         string
+		severity, want string
+	}{
+		{503, "503 Service Unavailable", monitor.SeverityCritical, "Stats server error: 503 Service Unavailable"},
+		{404, "404 Not Found", monitor.SeverityWarning, "Stats endpoint missing: 404 Not Found"},
+		{403, "403 Forbidden", monitor.SeverityWarning, "Stats access denied: 403 Forbidden"},
+		{400, "400 Bad Request", monitor.SeverityWarning, "Unexpected stats response: 400 Bad Request"},
+	}
+	for _, tt := range tests {
+		a := statusAlert("test", &monitor.StatusError{Code: tt.code, Status: tt.status}, time.Time{})
+		if a.Severity != tt.severity || a.Message != tt.want {
+			t.Errorf("statusAlert(%d) = %s %q, want %s %q", tt.code, a.Severity, a.Message, tt.severity, tt.want)
+		}
+	}
+}
+
+func TestServiceArgs(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if _, _, err := loadConfig(fs, nil); err != nil {
+		t.Fatal(err)
+	}
+	logFile, _ := filepath.Abs("alerts.log")
+	args := []string{"watch", "-service", "install", "-fetch-error-message", "service", "-quiet",
+		"--service=stop", "-log-file", "alerts.log", "-interval", "10s"}
+	want := []string{"watch", "-fetch-error-message", "service", "-quiet", "-log-file=" + logFile, "-interval", "10s"}
+	got, err := serviceArgs(fs, args)
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("serviceArgs = %q, %v, want %q", got, err, want)
+	}
+}
+
+func TestValidateTempCrit(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.TempCrit = 80
+	if err := cfg.validate(); err == nil {
+		t.Error("-temp-crit without -temp-limit: expected an error")
+	}
+	cfg.TempLimit = 70
+	if err := cfg.validate(); err != nil {
+		t.Errorf("-temp-crit with -temp-limit: %v", err)
+	}
+}
+
+func TestValidateSummaryFormat(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Summary = true
+	if err := cfg.validate(); err != nil {
+		t.Errorf("-summary: %v", err)
+	}
+	cfg.Format = "json"
+	if err := cfg.validate(); err == nil {
+		t.Error("-summary with -format json: expected an error")
+	}
+}
+
+func TestLoadConfigEnvCLIFlags(t *testing.T) {
+	tests := []struct {
+		env, value string
+		args       []string
+	}{
+		{"STATS_ONCE", "true", []string{"watch"}},
+		{"STATS_SERVICE", "restart", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			t.Setenv(tt.env, tt.value)
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			if _, _, err := loadConfig(fs, tt.args); err == nil {
+				t.Errorf("%s=%s %q: expected an error", tt.env, tt.value, tt.args)
+			}
+		})
+	}
+}
+
+func TestLoadConfigCommand(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	_, cli, err := loadConfig(fs, []string{"check", "-url", "http://srv/_stats"})
+	if err != nil || cli.command != "check" || !cli.once {
+		t.Errorf("check: command %q, once %v, err %v", cli.command, cli.once, err)
+	}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if _, _, err := loadConfig(fs, []string{"watch", "-once"}); err == nil {
+		t.Error("watch -once: expected an error")
+	}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if _, _, err := loadConfig(fs, []string{"-json-summary"}); err == nil {
+		t.Error("-json-summary without -once: expected an error")
+	}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if _, _, err := loadConfig(fs, []string{"check", "-json-summary"}); err != nil {
+		t.Errorf("check -json-summary: %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leonidSpiri/go-homework/monitor"
+)
+
+var promGauges = []struct {
+	metric string
+	name   string
+	help   string
+}{
+	{monitor.MetricLoadAvg, "load_average", "Load average reported by the server."},
+	{monitor.MetricMemory, "memory_usage_ratio", "Used memory as a fraction of total memory."},
+	{monitor.MetricDisk, "disk_usage_ratio", "Used disk space as a fraction of total disk space."},
+	{monitor.MetricNetwork, "network_usage_ratio", "Used network bandwidth as a fraction of capacity."},
+	{monitor.MetricSwap, "swap_usage_ratio", "Used swap as a fraction of total swap."},
+	{monitor.MetricTemperature, "cpu_temperature_celsius", "CPU temperature reported by the server."},
+}
+
+// gaugeSet хранит последние значения метрик по каждому серверу и отдаёт их
+// в текстовом формате Prometheus. Рядом с каждым значением — gauge
+// <метрика>_alerting: 1, пока по метрике держится тревога с учётом
+// -alert-consecutive и -hysteresis, а с нижней границей ещё и
+// <метрика>_low_alerting.
+type gaugeSet struct {
+	mu       sync.Mutex
+	values   map[string]map[string]float64 // метрика -> сервер -> значение
+	alerting map[string]map[string]float64 // метрика -> сервер -> 0 или 1
+}
+
+func newGaugeSet() *gaugeSet {
+	return &gaugeSet{
+		values:   make(map[string]map[string]float64),
+		alerting: make(map[string]map[string]float64),
+	}
+}
+
+// update запоминает значения всех проверок опроса; firing — тревоги,
+// которые держатся после подавления коротких всплесков и мёртвой зоны.
+func (g *gaugeSet) update(server string, alerts, firing []monitor.Alert) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	active := make(map[string]bool, len(firing))
+	for _, a := range firing {
+		active[a.Metric] = true
+	}
+	for _, a := range alerts {
+		alerting := 0.0
+		if active[a.Metric] {
+			alerting = 1
+		}
+		setGauge(g.values, a.Metric, server, a.Value)
+		setGauge(g.alerting, a.Metric, server, alerting)
+	}
+}
+
+func setGauge(m map[string]map[string]float64, metric, server string, v float64) {
+	byServer, ok := m[metric]
+	if !ok {
+		byServer = make(map[string]float64)
+		m[metric] = byServer
+	}
+	byServer[server] = v
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (g *gaugeSet) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, gauge := range promGauges {
+		writeGauge(w, gauge.name, gauge.help, g.values[gauge.metric])
+	}
+	for _, gauge := range promGauges {
+		writeGauge(w, gauge.metric+"_alerting", "Whether "+gauge.metric+" is alerting (1) or not (0).",
+			g.alerting[gauge.metric])
+	}
+	for _, gauge := range promGauges {
+		low := gauge.metric + "_low"
+		writeGauge(w, low+"_alerting", "Whether "+gauge.metric+" is alerting for being below its floor (1) or not (0).",
+			g.alerting[low])
+	}
+}
+
+func writeGauge(w io.Writer, name, help string, byServer map[string]float64) {
+	if len(byServer) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+	servers := make([]string, 0, len(byServer))
+	for s := range byServer {
+		servers = append(servers, s)
+	}
+	sort.Strings(servers)
+	for _, s := range servers {
+		fmt.Fprintf(w, "%s{server=\"%s\"} %s\n",
+			name, labelEscaper.Replace(s), strconv.FormatFloat(byServer[s], 'g', -1, 64))
+	}
+}
+
+func serveMetrics(ctx context.Context, addr string, g *gaugeSet) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", g)
+	return serve(ctx, "metrics-addr", addr, mux)
+}
+
+// serve начинает слушать addr сразу, чтобы ошибка занятого порта всплыла
+// до входа в цикл опроса, и останавливает сервер по отмене ctx.
+func serve(ctx context.Context, flagName, addr string, h http.Handler) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen -%s %q: %w", flagName, addr, err)
+	}
+	srv := &http.Server{Handler: h, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	go srv.Serve(ln)
+	return nil
+}
@@ -0,0 +1,76 @@
+package monitor
+
+import "time"
+
+const (
+	MetricLoadAvg     = "load_average"
+	MetricMemory      = "memory_usage"
+	MetricDisk        = "disk_usage"
+	MetricNetwork     = "network_usage"
+	MetricSwap        = "swap_usage"
+	MetricTemperature = "cpu_temperature"
+	MetricFetch       = "stats_fetch" // статистику не удаётся получить
+	MetricHTTPStatus  = "http_status" // агент ответил кодом, отличным от 200
+)
+
+// Проверки нижних границ, Checks.Floors: у них своё состояние тревоги,
+// отдельное от превышения порога той же метрики.
+const (
+	MetricLoadLow        = "load_average_low"
+	MetricMemoryLow      = "memory_usage_low"
+	MetricDiskLow        = "disk_usage_low"
+	MetricNetworkLow     = "network_usage_low"
+	MetricSwapLow        = "swap_usage_low"
+	MetricTemperatureLow = "cpu_temperature_low"
+)
+
+const (
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+const (
+	StatusFiring   = "firing"
+	StatusOK       = "ok"
+	StatusResolved = "resolved"
+)
+
+// Alert — результат одной проверки. Evaluate возвращает его по каждой
+// метрике, в том числе со статусом StatusOK: по нему вызывающий код
+// отслеживает возврат метрики в норму.
+type Alert struct {
+	Metric    string    `json:"metric"`
+	Status    string    `json:"status"`
+	Severity  string    `json:"severity,omitempty"` // только у сработавших проверок
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Server    string    `json:"server"`
+	Timestamp time.Time `json:"timestamp"`
+	Message   string    `json:"message"`
+	Error     string    `json:"error,omitempty"` // причина у MetricFetch
+
+	op string // условие срабатывания из правила; пусто — значение выше порога
+}
+
+// InDeadband сообщает, что значение уже не превышает порог, но ещё не
+// отошло от него на долю hysteresis.
+func (a Alert) InDeadband(hysteresis float64) bool {
+	switch a.op {
+	case "", ">", ">=":
+		return a.Value > a.Threshold*(1-hysteresis)
+	case "<", "<=":
+		return a.Value < a.Threshold*(1+hysteresis)
+	}
+	return false
+}
+
+// Firing оставляет только сработавшие проверки.
+func Firing(alerts []Alert) []Alert {
+	var out []Alert
+	for _, a := range alerts {
+		if a.Status == StatusFiring {
+			out = append(out, a)
+		}
+	}
+	return out
+}
@@ -0,0 +1,255 @@
+package monitor
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Thresholds — пороги по встроенным метрикам. Доли использования задаются
+// от 0 до 1, нулевой порог температуры выключает её проверку.
+type Thresholds struct {
+	LoadAvg      float64
+	MemUsage     float64
+	DiskUsage    float64
+	NetworkUsage float64
+	SwapUsage    float64
+	Temperature  float64
+}
+
+// Checks — что и с какими порогами проверяет Evaluate.
+type Checks struct {
+	Limits    Thresholds
+	Crit      Thresholds      // пороги уровня critical, 0 — без второго уровня
+	Floors    Thresholds      // тревога, если значение ниже; 0 — без нижней границы
+	Disabled  map[string]bool // выключенные встроенные проверки по имени метрики
+	Rules     []Rule
+	ShowUsage bool   // дописывать процент использования к сообщениям о диске и сети
+	DiskUnit  string // DiskUnitMB, DiskUnitGB или DiskUnitAuto; пусто — мегабайты
+	Units     string // UnitsLegacy, UnitsBinary или UnitsSI для объёмов; пусто — UnitsLegacy
+}
+
+// Evaluate сравнивает разобранные значения с порогами и возвращает результат
+// по каждой метрике; печатью и рассылкой занимается вызывающий код. Проверки
+// с заведомо неверными данными агента пропускаются молча, Monitor же
+// сообщает о них в свой Logger.
+func Evaluate(st Stats, server string, checks Checks, now time.Time) []Alert {
+	return evaluate(st, server, checks, nil, now, log.New(io.Discard, "", 0))
+}
+
+// evaluate, в отличие от Evaluate, сглаживает доли использования через
+// avg, своё у каждого сервера, и пишет предупреждения о данных в logger.
+func evaluate(st Stats, server string, checks Checks, avg *ewma, now time.Time, logger *log.Logger) []Alert {
+	limits, crit, floors := checks.Limits, checks.Crit, checks.Floors
+	// до приведения к uint64: отрицательное значение превратилось бы в огромное
+	// отключённые проверки пропускаются вместе с проверкой данных для них
+	loadOK := false
+	switch load := st.LoadAvg; {
+	case checks.Disabled[MetricLoadAvg]:
+	case math.IsNaN(load) || math.IsInf(load, 0):
+		logger.Printf("%s: data quality: invalid load average %s, skipping the check", server, fmtFloat(load))
+	case load < 0:
+		logger.Printf("%s: data quality: negative load average %s, skipping the check", server, fmtFloat(load))
+	default:
+		loadOK = true
+	}
+	memOK := !checks.Disabled[MetricMemory] && checkUsage(logger, server, "memory", st.MemTotal, st.MemUsed)
+	diskOK := !checks.Disabled[MetricDisk] && checkUsage(logger, server, "disk", st.DiskTotal, st.DiskUsed)
+	netOK := !checks.Disabled[MetricNetwork] && checkUsage(logger, server, "network", st.NetCapacity, st.NetUsed)
+	// swap необязателен: старые агенты присылают только семь полей
+	swapOK := st.HasSwap && !checks.Disabled[MetricSwap] && checkUsage(logger, server, "swap", st.SwapTotal, st.SwapUsed)
+
+	loadAvg := st.LoadAvg
+	memTotal := uint64(st.MemTotal)
+	memUsed := uint64(st.MemUsed)
+	diskTotal := uint64(st.DiskTotal)
+	diskUsed := uint64(st.DiskUsed)
+	// полоса сети и её загрузка приходят в байтах в секунду
+	netCapBps := uint64(st.NetCapacity)
+	netUsedBps := uint64(st.NetUsed)
+
+	var alerts []Alert
+	// add записывает результат проверки, в том числе и без превышения порога,
+	// чтобы вызывающий код мог отследить возврат метрики в норму.
+	// Выше порога critical тревога получает этот уровень и его порог.
+	add := func(metric string, value, threshold, critical float64, alertMsg, okMsg string) {
+		status, severity, msg := StatusOK, "", okMsg
+		switch {
+		case critical > 0 && value > critical:
+			status, severity, msg, threshold = StatusFiring, SeverityCritical, alertMsg, critical
+		case value > threshold:
+			status, severity, msg = StatusFiring, SeverityWarning, alertMsg
+		}
+		alerts = append(alerts, Alert{
+			Metric:    metric,
+			Status:    status,
+			Severity:  severity,
+			Value:     value,
+			Threshold: threshold,
+			Server:    server,
+			Timestamp: now,
+			Message:   msg,
+		})
+	}
+
+	// addFloor проверяет нижнюю границу; как и правило с условием "<",
+	// тревога с гистерезисом снимается, когда значение поднимется выше границы.
+	addFloor := func(metric string, value, floor float64, alertMsg, okMsg string) {
+		if floor <= 0 {
+			return
+		}
+		a := Alert{
+			Metric:    metric,
+			Status:    StatusOK,
+			Value:     value,
+			Threshold: floor,
+			Server:    server,
+			Timestamp: now,
+			Message:   okMsg,
+			op:        "<",
+		}
+		if value < floor {
+			a.Status, a.Severity, a.Message = StatusFiring, SeverityWarning, alertMsg
+		}
+		alerts = append(alerts, a)
+	}
+
+	// 1) Load Average
+	if loadOK {
+		add(MetricLoadAvg, loadAvg, limits.LoadAvg, crit.LoadAvg,
+			fmt.Sprintf("Load Average is too high: %s", fmtFloat(loadAvg)),
+			fmt.Sprintf("Load Average back to normal: %s", fmtFloat(loadAvg)))
+		addFloor(MetricLoadLow, loadAvg, floors.LoadAvg,
+			fmt.Sprintf("Load Average is suspiciously low: %s", fmtFloat(loadAvg)),
+			fmt.Sprintf("Load Average back to normal: %s", fmtFloat(loadAvg)))
+	}
+
+	// 2) Memory
+	if memOK && memTotal > 0 {
+		memUsage := avg.add(MetricMemory, usageRatio(memUsed, memTotal))
+		memPercent := percent(memUsage)
+		add(MetricMemory, memUsage, limits.MemUsage, crit.MemUsage,
+			fmt.Sprintf("Memory usage too high: %d%%", memPercent),
+			fmt.Sprintf("Memory usage back to normal: %d%%", memPercent))
+		addFloor(MetricMemoryLow, memUsage, floors.MemUsage,
+			fmt.Sprintf("Memory usage suspiciously low: %d%%", memPercent),
+			fmt.Sprintf("Memory usage back to normal: %d%%", memPercent))
+	}
+
+	// 3) Disk
+	if diskOK && diskTotal > 0 {
+		diskUsage := avg.add(MetricDisk, usageRatio(diskUsed, diskTotal))
+		freeBytes := int64(diskTotal) - int64(diskUsed)
+		if freeBytes < 0 {
+			freeBytes = 0
+		}
+		free := formatDiskSize(freeBytes, checks.DiskUnit, checks.Units)
+		usage := ""
+		if checks.ShowUsage {
+			usage = fmt.Sprintf(" (%d%% used)", percent(diskUsage))
+		}
+		add(MetricDisk, diskUsage, limits.DiskUsage, crit.DiskUsage,
+			fmt.Sprintf("Free disk space is too low: %s left%s", free, usage),
+			fmt.Sprintf("Free disk space back to normal: %s left%s", free, usage))
+		addFloor(MetricDiskLow, diskUsage, floors.DiskUsage,
+			fmt.Sprintf("Disk usage suspiciously low: %d%% used", percent(diskUsage)),
+			fmt.Sprintf("Disk usage back to normal: %d%% used", percent(diskUsage)))
+	}
+
+	// 4) Network
+	if netOK && netCapBps > 0 {
+		netUsage := avg.add(MetricNetwork, usageRatio(netUsedBps, netCapBps))
+		freeBps := int64(netCapBps) - int64(netUsedBps)
+		if freeBps < 0 {
+			freeBps = 0
+		}
+		// свободная полоса в мегабитах/сек (SI): Bps * 8 / 1_000_000
+		freeMbit := float64(freeBps) * 8 / 1_000_000.0
+		usage := ""
+		if checks.ShowUsage {
+			usage = fmt.Sprintf(" (%d%% used)", percent(netUsage))
+		}
+		add(MetricNetwork, netUsage, limits.NetworkUsage, crit.NetworkUsage,
+			fmt.Sprintf("Network bandwidth usage high: %s Mbit/s available%s", fmtFloat(freeMbit), usage),
+			fmt.Sprintf("Network bandwidth usage back to normal: %s Mbit/s available%s", fmtFloat(freeMbit), usage))
+		addFloor(MetricNetworkLow, netUsage, floors.NetworkUsage,
+			fmt.Sprintf("Network bandwidth usage suspiciously low: %d%% used", percent(netUsage)),
+			fmt.Sprintf("Network bandwidth usage back to normal: %d%% used", percent(netUsage)))
+	}
+
+	// 5) Swap
+	if swapOK && st.SwapTotal > 0 {
+		swapUsage := avg.add(MetricSwap, usageRatio(uint64(st.SwapUsed), uint64(st.SwapTotal)))
+		swapPercent := percent(swapUsage)
+		add(MetricSwap, swapUsage, limits.SwapUsage, crit.SwapUsage,
+			fmt.Sprintf("Swap usage too high: %d%%", swapPercent),
+			fmt.Sprintf("Swap usage back to normal: %d%%", swapPercent))
+		addFloor(MetricSwapLow, swapUsage, floors.SwapUsage,
+			fmt.Sprintf("Swap usage suspiciously low: %d%%", swapPercent),
+			fmt.Sprintf("Swap usage back to normal: %d%%", swapPercent))
+	}
+
+	// 6) CPU temperature, десятое поле, после swap
+	if st.HasTemperature {
+		temp := st.Temperature
+		if limits.Temperature > 0 {
+			add(MetricTemperature, temp, limits.Temperature, crit.Temperature,
+				fmt.Sprintf("CPU temperature too high: %sC", fmtFloat(temp)),
+				fmt.Sprintf("CPU temperature back to normal: %sC", fmtFloat(temp)))
+		}
+		addFloor(MetricTemperatureLow, temp, floors.Temperature,
+			fmt.Sprintf("CPU temperature suspiciously low: %sC", fmtFloat(temp)),
+			fmt.Sprintf("CPU temperature back to normal: %sC", fmtFloat(temp)))
+	}
+
+	for _, r := range checks.Rules {
+		a, ok := r.evaluate(st.Fields)
+		if !ok {
+			logger.Printf("%s: rule %s: response has no field %d, skipping the check", server, r.Name, r.Field)
+			continue
+		}
+		a.Server, a.Timestamp = server, now
+		alerts = append(alerts, a)
+	}
+
+	return alerts
+}
+
+// usageRatio ограничивает долю отрезком [0, 1]: неверные пары отсеивает
+// checkUsage, а здесь процент больше 100 не получится и при ошибке в расчётах.
+func usageRatio(used, total uint64) float64 {
+	return min(max(float64(used)/float64(total), 0), 1)
+}
+
+// checkUsage отсеивает заведомо неверные пары total/used от агента:
+// по ним вместо ложной тревоги печатается предупреждение.
+func checkUsage(logger *log.Logger, server, name string, total, used float64) bool {
+	switch {
+	case total < 0 || used < 0:
+		logger.Printf("%s: data quality: negative %s value (total %s, used %s), skipping the check",
+			server, name, fmtFloat(total), fmtFloat(used))
+		return false
+	case used > total:
+		logger.Printf("%s: data quality: %s used %s exceeds total %s, skipping the check",
+			server, name, fmtFloat(used), fmtFloat(total))
+		return false
+	}
+	return true
+}
+
+func fmtFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// percent переводит долю в целые проценты. Для NaN и Inf приведение к int64
+// не определено, поэтому они дают 0.
+func percent(ratio float64) int64 {
+	v := math.Round(100 * ratio)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0
+	}
+	return int64(v)
+}
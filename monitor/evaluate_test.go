@@ -0,0 +1,87 @@
+package monitor
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseEvaluate(t *testing.T) {
+	st, err := Parse("50,100,95,100,10,100,10", nil)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	checks := Checks{
+		Limits:   Thresholds{LoadAvg: 30, MemUsage: 0.8, DiskUsage: 0.9, NetworkUsage: 0.9},
+		Crit:     Thresholds{MemUsage: 0.9},
+		Disabled: map[string]bool{MetricLoadAvg: true},
+	}
+	got := map[string]string{}
+	for _, a := range Evaluate(st, "test", checks, time.Now()) {
+		got[a.Metric] = a.Status + "/" + a.Severity
+	}
+	want := map[string]string{
+		MetricMemory:  StatusFiring + "/" + SeverityCritical,
+		MetricDisk:    StatusOK + "/",
+		MetricNetwork: StatusOK + "/",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Evaluate = %v, want %v", got, want)
+	}
+	for metric, w := range want {
+		if got[metric] != w {
+			t.Errorf("%s: %q, want %q", metric, got[metric], w)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	var pe ParseError
+	if _, err := Parse("1,2,3", nil); !errors.As(err, &pe) {
+		t.Errorf("Parse = %v, want a ParseError", err)
+	}
+}
+
+func TestEvaluateFloors(t *testing.T) {
+	st, err := NewStats([]float64{0.01, 100, 50, 100, 50, 100, 0})
+	if err != nil {
+		t.Fatalf("NewStats: %v", err)
+	}
+	checks := Checks{
+		Limits: Thresholds{LoadAvg: 30, MemUsage: 0.8, DiskUsage: 0.9, NetworkUsage: 0.9},
+		Floors: Thresholds{LoadAvg: 0.1, NetworkUsage: 0.01, MemUsage: 0.2},
+	}
+	var got []string
+	for _, a := range Firing(Evaluate(st, "test", checks, time.Now())) {
+		got = append(got, a.Message)
+	}
+	want := []string{
+		"Load Average is suspiciously low: 0.01",
+		"Network bandwidth usage suspiciously low: 0% used",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("alerts = %q, want %q", got, want)
+	}
+}
+
+func TestFormatDiskSize(t *testing.T) {
+	tests := []struct {
+		bytes       int64
+		unit, units string
+		want        string
+	}{
+		{5 << 30, DiskUnitMB, "", "5120 Mb"},
+		{5 << 30, DiskUnitAuto, UnitsLegacy, "5 Gb"},
+		{5 << 30, DiskUnitMB, UnitsBinary, "5120 MiB"},
+		{3 << 40, DiskUnitAuto, UnitsBinary, "3 TiB"},
+		{5 << 30, DiskUnitMB, UnitsSI, "5368 MB"},
+		{5 << 30, DiskUnitAuto, UnitsSI, "5.4 GB"},
+		{2e12, DiskUnitAuto, UnitsSI, "2 TB"},
+	}
+	for _, tt := range tests {
+		if got := formatDiskSize(tt.bytes, tt.unit, tt.units); got != tt.want {
+			t.Errorf("formatDiskSize(%d, %q, %q) = %q, want %q", tt.bytes, tt.unit, tt.units, got, tt.want)
+		}
+	}
+}
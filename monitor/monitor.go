@@ -0,0 +1,247 @@
+// Package monitor опрашивает адрес статистики сервера (_stats), разбирает
+// ответ и сравнивает значения с порогами. Печатью, рассылкой и подавлением
+// повторных оповещений занимается вызывающий код: результат каждого опроса
+// передаётся ему через Monitor.OnResult.
+package monitor
+
+import (
+	"context"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultInterval — период опроса, если Monitor.Interval не задан.
+const DefaultInterval = 5 * time.Second
+
+// Result — итог одного опроса.
+type Result struct {
+	Server   string
+	Stats    Stats   // пусто, если опрос не удался
+	Alerts   []Alert // результат каждой проверки, в том числе пройденной
+	Err      error
+	Started  time.Time
+	Duration time.Duration
+}
+
+// Monitor опрашивает один сервер. Обязателен только URL, остальные поля
+// можно не заполнять.
+type Monitor struct {
+	URL     string
+	Server  string       // имя сервера в оповещениях и логе
+	Client  *http.Client // nil — http.DefaultClient
+	Request Request
+	Parser  Parser // nil — CSV с разделителем, определяемым по ответу
+	Checks  Checks
+	// Settings, если задана, вызывается перед каждым опросом вместо Checks:
+	// так пороги можно менять на ходу.
+	Settings func() Checks
+
+	Interval         time.Duration // 0 — DefaultInterval
+	Deadline         time.Duration // предел на весь опрос вместе с разбором; 0 — без предела
+	Jitter           float64       // случайный сдвиг каждого опроса, доля Interval
+	StartDelay       time.Duration
+	RandomStartDelay bool    // пауза перед первым опросом случайна в пределах StartDelay
+	SkipMissed       bool    // после опроса дольше Interval пропускать накопившийся тик
+	LoadWindow       int     // load average усредняется по стольким последним опросам
+	EWMAAlpha        float64 // вес нового значения в сглаживании долей использования; 0 — без сглаживания
+	Verbose          bool    // печатать значения каждого успешного опроса
+	Clock            Clock   // nil — RealClock
+	// Logger получает предупреждения о данных агента и долгих опросах, а с
+	// Verbose и Request.Debug — подробности опроса; nil — стандартный логгер
+	// пакета log.
+	Logger *log.Logger
+
+	// OnResult получает каждый опрос, кроме прерванного отменой ctx.
+	OnResult func(Result)
+
+	once       sync.Once
+	loadWindow *sampleWindow
+	ewma       *ewma
+}
+
+// Run опрашивает сервер каждые Interval до отмены ctx.
+func (m *Monitor) Run(ctx context.Context) {
+	clk := m.clock()
+	interval := m.interval()
+	if delay := m.startDelay(); delay > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-clk.After(delay):
+		}
+	}
+
+	// без Jitter опросы идут строго по тикеру, с ним каждый следующий
+	// планируется заново со случайным сдвигом
+	var tick <-chan time.Time
+	if m.Jitter == 0 {
+		ticker := clk.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C()
+	}
+
+	for {
+		r := m.Poll(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		// тикер копит один пропущенный тик, и следующий опрос начался бы сразу
+		overran := r.Duration > interval
+		if overran {
+			skip := ""
+			if m.SkipMissed && m.Jitter == 0 {
+				skip = ", skipping the missed tick"
+			}
+			m.logger().Printf("%s: poll took %s, longer than the poll interval %s%s", m.Server,
+				r.Duration.Round(time.Millisecond), interval, skip)
+		}
+		if m.OnResult != nil {
+			m.OnResult(r)
+		}
+
+		if m.Jitter > 0 {
+			tick = clk.After(jittered(interval, m.Jitter))
+		} else if overran && m.SkipMissed {
+			select {
+			case <-tick:
+			default:
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		}
+	}
+}
+
+// Poll один раз загружает и проверяет статистику.
+func (m *Monitor) Poll(ctx context.Context) Result {
+	clk := m.clock()
+	r := Result{Server: m.Server, Started: clk.Now()}
+	if m.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.Deadline)
+		defer cancel()
+	}
+	req := m.Request
+	if req.Logger == nil {
+		req.Logger = m.Logger
+	}
+	body, err := req.Fetch(ctx, clk, m.client(), m.URL)
+	if err == nil {
+		r.Stats, r.Alerts, err = m.check(ctx, body)
+	}
+	r.Err = err
+	r.Duration = clk.Now().Sub(r.Started)
+	return r
+}
+
+// Check проверяет уже полученное тело ответа _stats.
+func (m *Monitor) Check(ctx context.Context, body string) Result {
+	clk := m.clock()
+	r := Result{Server: m.Server, Started: clk.Now()}
+	r.Stats, r.Alerts, r.Err = m.check(ctx, body)
+	r.Duration = clk.Now().Sub(r.Started)
+	return r
+}
+
+// CheckReader проверяет сохранённый ответ _stats, например из файла;
+// читается не больше Request.MaxBodySize байт.
+func (m *Monitor) CheckReader(rd io.Reader) Result {
+	body, err := readAllTrim(rd, m.Request.maxBody())
+	if err != nil {
+		return Result{Server: m.Server, Started: m.clock().Now(), Err: err}
+	}
+	return m.Check(context.Background(), body)
+}
+
+func (m *Monitor) check(ctx context.Context, body string) (Stats, []Alert, error) {
+	m.once.Do(m.init)
+	st, err := parseBody(ctx, m.parser(), body)
+	if err != nil {
+		return Stats{}, nil, err
+	}
+
+	if m.Verbose {
+		m.logger().Printf("%s: poll ok: load=%s mem=%s/%s disk=%s/%s net=%s/%s", m.Server,
+			fmtFloat(st.LoadAvg), fmtFloat(st.MemUsed), fmtFloat(st.MemTotal),
+			fmtFloat(st.DiskUsed), fmtFloat(st.DiskTotal), fmtFloat(st.NetUsed), fmtFloat(st.NetCapacity))
+	}
+
+	// некорректное значение в окно не попадает, о нём предупредит evaluate
+	if m.loadWindow != nil && st.LoadAvg >= 0 {
+		// среднее округляется до сотых, как load average в /proc/loadavg
+		st.LoadAvg = math.Round(m.loadWindow.add(st.LoadAvg)*100) / 100
+		st.Fields[0] = st.LoadAvg
+	}
+
+	checks := m.Checks
+	if m.Settings != nil {
+		checks = m.Settings()
+	}
+	return st, evaluate(st, m.Server, checks, m.ewma, m.clock().Now(), m.logger()), nil
+}
+
+func (m *Monitor) init() {
+	if m.LoadWindow > 1 {
+		m.loadWindow = newSampleWindow(m.LoadWindow)
+	}
+	if m.EWMAAlpha > 0 && m.EWMAAlpha < 1 {
+		m.ewma = newEWMA(m.EWMAAlpha)
+	}
+}
+
+func (m *Monitor) clock() Clock {
+	if m.Clock == nil {
+		return RealClock{}
+	}
+	return m.Clock
+}
+
+func (m *Monitor) logger() *log.Logger {
+	if m.Logger == nil {
+		return log.Default()
+	}
+	return m.Logger
+}
+
+func (m *Monitor) interval() time.Duration {
+	if m.Interval <= 0 {
+		return DefaultInterval
+	}
+	return m.Interval
+}
+
+func (m *Monitor) client() *http.Client {
+	if m.Client == nil {
+		return http.DefaultClient
+	}
+	return m.Client
+}
+
+func (m *Monitor) parser() Parser {
+	if m.Parser == nil {
+		return csvParser{}
+	}
+	return m.Parser
+}
+
+// startDelay — пауза перед первым опросом; со случайной паузой серверы,
+// запущенные одновременно, расходятся в пределах StartDelay.
+func (m *Monitor) startDelay() time.Duration {
+	if m.RandomStartDelay && m.StartDelay > 0 {
+		return time.Duration(rand.Int63n(int64(m.StartDelay)))
+	}
+	return m.StartDelay
+}
+
+// jittered сдвигает d случайно в пределах ±jitter (доля от d).
+func jittered(d time.Duration, jitter float64) time.Duration {
+	return time.Duration(float64(d) * (1 + jitter*(2*rand.Float64()-1)))
+}
@@ -0,0 +1,153 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testMonitor(srv *httptest.Server) *Monitor {
+	return &Monitor{
+		URL:    srv.URL,
+		Server: "test",
+		Client: srv.Client(),
+		Checks: Checks{
+			Limits: Thresholds{LoadAvg: 30, MemUsage: 0.8, DiskUsage: 0.9, NetworkUsage: 0.9},
+		},
+	}
+}
+
+func TestPoll(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			name: "normal",
+			body: "1.5,8589934592,2147483648,107374182400,10737418240,125000000,12500000\n",
+			want: nil,
+		},
+		{
+			name: "high load",
+			body: "42,8589934592,2147483648,107374182400,10737418240,125000000,12500000\n",
+			want: []string{"Load Average is too high: 42"},
+		},
+		{
+			name: "high memory",
+			body: "1,8589934592,7730941133,107374182400,10737418240,125000000,12500000\n",
+			want: []string{"Memory usage too high: 90%"},
+		},
+		{
+			name: "low disk",
+			body: "1,8589934592,2147483648,107374182400,102005473280,125000000,12500000\n",
+			want: []string{"Free disk space is too low: 5120 Mb left"},
+		},
+		{
+			// 125 000 000 байт/с — это 1000 Мбит/с, свободно 5 000 000 байт/с = 40 Мбит/с
+			name: "high network",
+			body: "1,8589934592,2147483648,107374182400,10737418240,125000000,120000000\n",
+			want: []string{"Network bandwidth usage high: 40 Mbit/s available"},
+		},
+		{
+			name: "everything at once",
+			body: "31,100,81,1048576000,1048576000,1000000,950000\n",
+			want: []string{
+				"Load Average is too high: 31",
+				"Memory usage too high: 81%",
+				"Free disk space is too low: 0 Mb left",
+				"Network bandwidth usage high: 0.4 Mbit/s available",
+			},
+		},
+		{
+			name: "high swap",
+			body: "1,100,10,100,10,100,10,2048,1536\n",
+			want: []string{"Swap usage too high: 75%"},
+		},
+		{
+			name: "extra fields are ignored",
+			body: "42,100,10,100,10,100,10,7\n",
+			want: []string{"Load Average is too high: 42"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			r := testMonitor(srv).Poll(context.Background())
+			if r.Err != nil {
+				t.Fatalf("Poll: %v", r.Err)
+			}
+			var got []string
+			for _, a := range Firing(r.Alerts) {
+				got = append(got, a.Message)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("alerts = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPollErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"bad status", http.StatusInternalServerError, "1,2,3,4,5,6,7"},
+		{"too few fields", http.StatusOK, "1,2,3,4,5,6"},
+		{"not a number", http.StatusOK, "1,2,x,4,5,6,7"},
+		{"not finite", http.StatusOK, "NaN,2,1,4,3,6,5"},
+		{"out of range", http.StatusOK, "1,1e400,1,4,3,6,5"},
+		{"empty body", http.StatusOK, ""},
+		{"body too large", http.StatusOK, "1,2,3,4,5,6,7" + strings.Repeat(" ", DefaultMaxBodySize)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			if r := testMonitor(srv).Poll(context.Background()); r.Err == nil {
+				t.Fatal("Poll: expected an error")
+			}
+		})
+	}
+}
+
+func TestMonitorLogger(t *testing.T) {
+	var buf bytes.Buffer
+	m := &Monitor{Server: "test", Logger: log.New(&buf, "", 0)}
+	if r := m.Check(context.Background(), "1,100,200,100,10,100,10"); r.Err != nil {
+		t.Fatalf("Check: %v", r.Err)
+	}
+	want := "test: data quality: memory used 200 exceeds total 100, skipping the check\n"
+	if got := buf.String(); got != want {
+		t.Errorf("log = %q, want %q", got, want)
+	}
+}
+
+func TestEWMA(t *testing.T) {
+	e := newEWMA(0.5)
+	var got []float64
+	for _, v := range []float64{0.2, 1, 0.6} {
+		got = append(got, e.add(MetricMemory, v))
+	}
+	if want := []float64{0.2, 0.6, 0.6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ewma = %v, want %v", got, want)
+	}
+	if v := (*ewma)(nil).add(MetricMemory, 0.9); v != 0.9 {
+		t.Errorf("nil ewma = %v, want 0.9", v)
+	}
+}
@@ -0,0 +1,60 @@
+// Package monitortest содержит вспомогательные типы для тестов кода,
+// использующего пакет monitor.
+package monitortest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/leonidSpiri/go-homework/monitor"
+)
+
+// Clock — часы для тестов: время стоит на месте, пока его не сдвинет
+// Advance, тики посылает Tick, а After срабатывает сразу.
+type Clock struct {
+	mu   sync.Mutex
+	now  time.Time
+	tick chan time.Time
+}
+
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now, tick: make(chan time.Time)}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance сдвигает время на d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Tick посылает тик всем тикерам этих часов и ждёт, пока его примут.
+func (c *Clock) Tick() {
+	c.tick <- c.Now()
+}
+
+func (c *Clock) NewTicker(time.Duration) monitor.Ticker {
+	return ticker{c.tick}
+}
+
+func (c *Clock) After(time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- c.Now()
+	return ch
+}
+
+type ticker struct {
+	c chan time.Time
+}
+
+func (t ticker) C() <-chan time.Time {
+	return t.c
+}
+
+func (ticker) Stop() {}
@@ -0,0 +1,308 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Parser превращает тело ответа в семь значений в порядке CSV-формата:
+// load, mem_total, mem_used, disk_total, disk_used, net_capacity, net_used,
+// а если агент их присылает — ещё swap_total, swap_used и temperature.
+type Parser interface {
+	Parse(body string) ([]float64, error)
+}
+
+// ParseError отличает неразборчивый ответ от неудачной загрузки.
+type ParseError struct {
+	Err error
+}
+
+func (e ParseError) Error() string { return e.Err.Error() }
+
+func (e ParseError) Unwrap() error { return e.Err }
+
+// CSVFormat описывает разделители CSV-ответа. Пустой Delim означает,
+// что разделитель определяется по первой строке, пустой Decimal — точку.
+type CSVFormat struct {
+	Delim     string
+	Decimal   string
+	Thousands string // разделитель разрядов; пусто — не ожидается
+}
+
+// number разбирает одно поле с учётом десятичного разделителя.
+func (f CSVFormat) number(p string) (float64, error) {
+	if f.Thousands != "" {
+		p = strings.ReplaceAll(p, f.Thousands, "")
+	}
+	if f.Decimal != "" && f.Decimal != "." {
+		p = strings.Replace(p, f.Decimal, ".", 1)
+	}
+	v, err := strconv.ParseFloat(p, 64)
+	if err != nil {
+		return 0, err
+	}
+	// ParseFloat принимает "NaN" и "Inf", с ними сравнения с порогами теряют смысл
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, errors.New("not a finite number")
+	}
+	return v, nil
+}
+
+// detect подбирает разделитель по строке, если он не задан явно:
+// табуляция и точка с запятой важнее запятой, которая может оказаться
+// десятичным разделителем.
+func (f CSVFormat) detect(line string) CSVFormat {
+	if f.Delim != "" {
+		return f
+	}
+	switch {
+	case strings.Contains(line, "\t"):
+		f.Delim = "\t"
+	case strings.Contains(line, ";"), f.Decimal == ",", f.Thousands == ",":
+		f.Delim = ";"
+	default:
+		f.Delim = ","
+	}
+	return f
+}
+
+type csvParser struct {
+	format      CSVFormat
+	averageRows bool
+}
+
+func (p csvParser) Parse(body string) ([]float64, error) {
+	if p.averageRows {
+		return parseCSVAverage(body, p.format)
+	}
+	return parseCSVNumbers(body, p.format)
+}
+
+type jsonParser struct{}
+
+func (p jsonParser) Parse(body string) ([]float64, error) {
+	st, err := p.parseStats(body)
+	if err != nil {
+		return nil, err
+	}
+	return st.Fields, nil
+}
+
+// parseStats раскладывает ключи JSON по полям Stats: в отличие от CSV,
+// в JSON температура может прийти без swap, и тогда swap не проверяется.
+func (jsonParser) parseStats(body string) (Stats, error) {
+	var v struct {
+		Load        *float64 `json:"load"`
+		MemTotal    *float64 `json:"mem_total"`
+		MemUsed     *float64 `json:"mem_used"`
+		DiskTotal   *float64 `json:"disk_total"`
+		DiskUsed    *float64 `json:"disk_used"`
+		NetCapacity *float64 `json:"net_capacity"`
+		NetUsed     *float64 `json:"net_used"`
+		SwapTotal   *float64 `json:"swap_total"`
+		SwapUsed    *float64 `json:"swap_used"`
+		Temperature *float64 `json:"temperature"`
+	}
+	if err := json.Unmarshal([]byte(body), &v); err != nil {
+		return Stats{}, fmt.Errorf("parse json: %w", err)
+	}
+	fields := []struct {
+		name  string
+		value *float64
+	}{
+		{"load", v.Load},
+		{"mem_total", v.MemTotal},
+		{"mem_used", v.MemUsed},
+		{"disk_total", v.DiskTotal},
+		{"disk_used", v.DiskUsed},
+		{"net_capacity", v.NetCapacity},
+		{"net_used", v.NetUsed},
+	}
+	out := make([]float64, 0, len(fields)+3)
+	for _, f := range fields {
+		if f.value == nil {
+			return Stats{}, fmt.Errorf("parse json: missing field %q", f.name)
+		}
+		out = append(out, *f.value)
+	}
+	hasSwap := v.SwapTotal != nil && v.SwapUsed != nil
+	if hasSwap {
+		out = append(out, *v.SwapTotal, *v.SwapUsed)
+	}
+	if v.Temperature != nil {
+		// в Fields температура стоит на своём месте после swap, как в CSV
+		if !hasSwap {
+			out = append(out, 0, 0)
+		}
+		out = append(out, *v.Temperature)
+	}
+	st, err := NewStats(out)
+	if err != nil {
+		return Stats{}, err
+	}
+	if !hasSwap {
+		st.HasSwap, st.SwapTotal, st.SwapUsed = false, 0, 0
+	}
+	return st, nil
+}
+
+// statsParser — разбор, который сам раскладывает ответ по полям Stats,
+// когда позиций в Parse для этого недостаточно.
+type statsParser interface {
+	parseStats(body string) (Stats, error)
+}
+
+// parseStats разбирает тело через p; любая ошибка возвращается как ParseError.
+func parseStats(p Parser, body string) (Stats, error) {
+	if sp, ok := p.(statsParser); ok {
+		st, err := sp.parseStats(body)
+		if err != nil {
+			return Stats{}, ParseError{err}
+		}
+		return st, nil
+	}
+	values, err := p.Parse(body)
+	if err != nil {
+		return Stats{}, ParseError{err}
+	}
+	st, err := NewStats(values)
+	if err != nil {
+		return Stats{}, ParseError{err}
+	}
+	return st, nil
+}
+
+// Parse разбирает тело ответа _stats; с nil p — как CSV с разделителем,
+// определяемым по ответу. Любая ошибка разбора возвращается как ParseError.
+func Parse(body string, p Parser) (Stats, error) {
+	if p == nil {
+		p = csvParser{}
+	}
+	return parseStats(p, body)
+}
+
+// NewParser возвращает разбор ответа в формате csv или json; с averageRows
+// CSV-ответ из нескольких строк усредняется по столбцам.
+func NewParser(format string, csv CSVFormat, averageRows bool) (Parser, error) {
+	switch format {
+	case "csv":
+		return csvParser{format: csv, averageRows: averageRows}, nil
+	case "json":
+		return jsonParser{}, nil
+	}
+	return nil, fmt.Errorf("unknown response format %q: must be csv or json", format)
+}
+
+func parseCSVNumbers(s string, f CSVFormat) ([]float64, error) {
+	line, rest, _ := strings.Cut(s, "\n")
+	f = f.detect(line)
+	// некоторые агенты перед данными отдают строку заголовка вида
+	// load,mem_total,mem_used,...; в этом случае берём следующую строку
+	if isHeaderLine(line, f) {
+		line, _, _ = strings.Cut(rest, "\n")
+	}
+	return parseCSVLine(line, f)
+}
+
+func parseCSVLine(line string, f CSVFormat) ([]float64, error) {
+	parts := strings.Split(strings.TrimSpace(line), f.Delim)
+	var out []float64
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		v, err := f.number(p)
+		if err != nil {
+			return nil, fmt.Errorf("parse number %q: %w", p, err)
+		}
+		out = append(out, v)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no numbers parsed")
+	}
+	return out, nil
+}
+
+// parseCSVAverage разбирает все строки с данными и возвращает средние по
+// каждому столбцу. Строки, которые не удалось разобрать, пропускаются;
+// если длины строк различаются, учитываются только общие столбцы.
+func parseCSVAverage(s string, f CSVFormat) ([]float64, error) {
+	lines := strings.Split(s, "\n")
+	f = f.detect(lines[0])
+	if isHeaderLine(lines[0], f) {
+		lines = lines[1:]
+	}
+	var (
+		sum  []float64
+		rows int
+	)
+	for _, line := range lines {
+		values, err := parseCSVLine(line, f)
+		if err != nil {
+			continue
+		}
+		if rows == 0 {
+			sum = make([]float64, len(values))
+		} else if len(values) < len(sum) {
+			sum = sum[:len(values)]
+		}
+		for i := range sum {
+			sum[i] += values[i]
+		}
+		rows++
+	}
+	if rows == 0 {
+		return nil, errors.New("no valid data rows")
+	}
+	for i := range sum {
+		sum[i] /= float64(rows)
+	}
+	return sum, nil
+}
+
+// isHeaderLine сообщает, что в строке нет ни одного числового поля.
+func isHeaderLine(line string, f CSVFormat) bool {
+	seen := false
+	for _, p := range strings.Split(line, f.Delim) {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if _, err := f.number(p); err == nil {
+			return false
+		}
+		seen = true
+	}
+	return seen
+}
+
+// parseBody разбирает тело, пока не истёк срок ctx. Сам разбор прервать
+// нельзя: по истечении срока он доработает в фоне, а результат пропадёт.
+// Без срока разбор идёт в вызывающей горутине — отдельная горутина на
+// каждый опрос нужна только там, где есть что ждать.
+func parseBody(ctx context.Context, p Parser, body string) (Stats, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		return parseStats(p, body)
+	}
+	type result struct {
+		st  Stats
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		st, err := parseStats(p, body)
+		done <- result{st, err}
+	}()
+	select {
+	case r := <-done:
+		return r.st, r.err
+	case <-ctx.Done():
+		return Stats{}, fmt.Errorf("parse response: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,96 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCSVNumbers(t *testing.T) {
+	comma := CSVFormat{Delim: ",", Decimal: "."}
+	tests := []struct {
+		name    string
+		body    string
+		format  CSVFormat
+		want    []float64
+		wantErr bool
+	}{
+		{name: "valid row", body: "1.5,100,20", format: comma, want: []float64{1.5, 100, 20}},
+		{name: "extra whitespace", body: "  1 ,\t2 ,3  ", format: comma, want: []float64{1, 2, 3}},
+		{name: "trailing comma", body: "1,2,3,", format: comma, want: []float64{1, 2, 3}},
+		{name: "empty fields are skipped", body: "1,,2, ,3", format: comma, want: []float64{1, 2, 3}},
+		{name: "only the first line is read", body: "1,2\n3,4", format: comma, want: []float64{1, 2}},
+		{name: "header line is skipped", body: "load,mem_total\n1,2\n3,4", format: comma, want: []float64{1, 2}},
+		{name: "delimiter detected", body: "1;2.5;3", format: CSVFormat{}, want: []float64{1, 2.5, 3}},
+		{name: "decimal comma", body: "1,5;2", format: CSVFormat{Delim: ";", Decimal: ","}, want: []float64{1.5, 2}},
+		{
+			name:   "thousands separator",
+			body:   "1.5;8,589,934,592;8.59e9",
+			format: CSVFormat{Delim: ";", Decimal: ".", Thousands: ","},
+			want:   []float64{1.5, 8589934592, 8.59e9},
+		},
+		{name: "empty input", body: "", format: comma, wantErr: true},
+		{name: "only delimiters", body: ",,,", format: comma, wantErr: true},
+		{name: "non-numeric field", body: "1,x,3", format: comma, wantErr: true},
+		{name: "not finite", body: "1,Inf,3", format: comma, wantErr: true},
+		{name: "header without data", body: "load,mem_total", format: comma, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCSVNumbers(tt.body, tt.format)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseCSVNumbers(%q) = %v, want an error", tt.body, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCSVNumbers(%q): %v", tt.body, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCSVNumbers(%q) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+type blockingParser chan struct{}
+
+func (p blockingParser) Parse(string) ([]float64, error) {
+	<-p
+	return []float64{1}, nil
+}
+
+func TestParseBody(t *testing.T) {
+	// без срока отмена ctx разбор не прерывает
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if st, err := parseBody(ctx, csvParser{}, "1,2,3,4,5,6,7"); err != nil || st.LoadAvg != 1 {
+		t.Errorf("canceled ctx: %v, %v", st, err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	p := make(blockingParser)
+	defer close(p)
+	if _, err := parseBody(ctx, p, ""); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expired deadline: err = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	p, _ := NewParser("json", CSVFormat{}, false)
+	body := `{"load":1,"mem_total":100,"mem_used":10,"disk_total":100,"disk_used":10,"net_capacity":100,"net_used":10,"temperature":55}`
+	st, err := Parse(body, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.HasSwap || !st.HasTemperature || st.Temperature != 55 {
+		t.Errorf("Parse = %+v, want temperature 55 without swap", st)
+	}
+	if len(st.Fields) != 10 || st.Fields[9] != 55 {
+		t.Errorf("Fields = %v, want temperature at index 9", st.Fields)
+	}
+}
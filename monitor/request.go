@@ -0,0 +1,275 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultMaxBodySize — предел тела ответа, если Request.MaxBodySize не задан.
+const DefaultMaxBodySize = 1 << 20
+
+var (
+	ErrAuthFailed = errors.New("authentication failed")
+	ErrBadStatus  = errors.New("unexpected status")
+)
+
+// StatusError — ответ с кодом, отличным от 200. Код 401 считается
+// ErrAuthFailed, остальные — ErrBadStatus.
+type StatusError struct {
+	Code   int
+	Status string // например "503 Service Unavailable"
+}
+
+func (e *StatusError) Error() string {
+	if e.Code == http.StatusUnauthorized {
+		return ErrAuthFailed.Error() + ": " + e.Status
+	}
+	return ErrBadStatus.Error() + ": " + e.Status
+}
+
+func (e *StatusError) Is(target error) bool {
+	if e.Code == http.StatusUnauthorized {
+		return target == ErrAuthFailed
+	}
+	return target == ErrBadStatus
+}
+
+// Request описывает, что добавить к каждому запросу статистики.
+// Учётные данные нигде не печатаются, в том числе в сообщениях об ошибках.
+type Request struct {
+	Token    string
+	User     string
+	Password string
+	Headers  http.Header
+
+	MaxBodySize    int64       // 0 — DefaultMaxBodySize
+	Debug          bool        // печатать заголовки и тела ответов в Logger
+	Logger         *log.Logger // nil — стандартный логгер пакета log
+	ContentTypes   []string    // пустой список — принимаем любой тип
+	Retries        int
+	RetryBaseDelay time.Duration
+}
+
+func (c Request) apply(req *http.Request) {
+	for k, vs := range c.Headers {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if c.User != "" {
+		req.SetBasicAuth(c.User, c.Password)
+	}
+}
+
+// Fetch загружает тело ответа, повторяя неудачные попытки с экспоненциальной
+// задержкой. Ошибки авторизации не повторяются, ожидание прерывается по ctx.
+func (c Request) Fetch(ctx context.Context, clk Clock, client *http.Client, url string) (string, error) {
+	delay := c.RetryBaseDelay
+	for attempt := 0; ; attempt++ {
+		body, err := c.fetchOnce(ctx, client, url)
+		if err == nil || attempt >= c.Retries || errors.Is(err, ErrAuthFailed) {
+			return body, err
+		}
+		select {
+		case <-ctx.Done():
+			return "", err
+		case <-clk.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (c Request) fetchOnce(ctx context.Context, client *http.Client, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	c.apply(req)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if c.Debug {
+		c.logExchange(req, resp)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		c.drain(req.URL.Redacted(), resp.Body)
+		return "", &StatusError{Code: resp.StatusCode, Status: resp.Status}
+	}
+	if err := c.checkContentType(resp.Header.Get("Content-Type")); err != nil {
+		c.drain(req.URL.Redacted(), resp.Body)
+		return "", err
+	}
+
+	// Transport распаковывает gzip сам, только если сам же его и запросил;
+	// с заголовком Accept-Encoding из -header или у агента, который сжимает
+	// без спроса, тело приходит сжатым
+	var body io.Reader = resp.Body
+	if !resp.Uncompressed && strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return "", fmt.Errorf("gzip body: %w", err)
+		}
+		defer zr.Close()
+		body = zr
+	}
+	if c.Debug {
+		raw, err := io.ReadAll(io.LimitReader(body, c.maxBody()+1))
+		if err != nil {
+			return "", err
+		}
+		c.logger().Printf("debug: %s: body (%d bytes): %q", req.URL.Redacted(), len(raw), raw)
+		body = bytes.NewReader(raw)
+	}
+	// ограничение размера действует на распакованное тело
+	return readAllTrim(body, c.maxBody())
+}
+
+// drain дочитывает тело ответа, который не будет разобран, чтобы соединение
+// вернулось в пул; с Debug тело печатается.
+func (c Request) drain(target string, r io.Reader) {
+	if !c.Debug {
+		io.Copy(io.Discard, r)
+		return
+	}
+	raw, _ := io.ReadAll(io.LimitReader(r, c.maxBody()))
+	c.logger().Printf("debug: %s: body (%d bytes): %q", target, len(raw), raw)
+}
+
+// sensitiveHeader — заголовки, значения которых Debug не печатает.
+func sensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range []string{"auth", "token", "key", "secret", "password", "cookie"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatHeaders(h http.Header) string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	for _, name := range names {
+		for _, v := range h[name] {
+			if sensitiveHeader(name) {
+				v = "[redacted]"
+			}
+			fmt.Fprintf(&sb, "\n    %s: %s", name, v)
+		}
+	}
+	return sb.String()
+}
+
+func (c Request) logExchange(req *http.Request, resp *http.Response) {
+	c.logger().Printf("debug: %s %s%s", req.Method, req.URL.Redacted(), formatHeaders(req.Header))
+	c.logger().Printf("debug: %s: %s %s%s", req.URL.Redacted(), resp.Proto, resp.Status, formatHeaders(resp.Header))
+}
+
+func (c Request) logger() *log.Logger {
+	if c.Logger == nil {
+		return log.Default()
+	}
+	return c.Logger
+}
+
+// checkContentType пропускает ответ без Content-Type: простые агенты его
+// часто не ставят, а HTML-страницу прокси он всё равно отсеет.
+func (c Request) checkContentType(header string) error {
+	if len(c.ContentTypes) == 0 || header == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return fmt.Errorf("unexpected content type %q: %v", header, err)
+	}
+	for _, t := range c.ContentTypes {
+		if mediaType == t {
+			return nil
+		}
+	}
+	return fmt.Errorf("unexpected content type %q, want %s", mediaType, strings.Join(c.ContentTypes, " or "))
+}
+
+// ErrorCategory коротко описывает причину неудачного опроса,
+// чтобы разные сбои можно было различить в выводе.
+func ErrorCategory(err error) string {
+	var (
+		dnsErr *net.DNSError
+		netErr net.Error
+		urlErr *url.Error
+	)
+	switch {
+	case errors.Is(err, ErrAuthFailed):
+		return "authentication failed"
+	case errors.Is(err, ErrBadStatus):
+		return "bad status"
+	case errors.As(err, &dnsErr):
+		return "dns lookup failed"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection refused"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &urlErr):
+		return "network error"
+	}
+	return "invalid response"
+}
+
+// maxBody — предел тела ответа с учётом значения по умолчанию.
+func (c Request) maxBody() int64 {
+	if c.MaxBodySize <= 0 {
+		return DefaultMaxBodySize
+	}
+	return c.MaxBodySize
+}
+
+// readAllTrim читает не больше limit байт; на байт больше читаем, чтобы
+// отличить тело ровно в limit от обрезанного.
+func readAllTrim(r io.Reader, limit int64) (string, error) {
+	var sb strings.Builder
+	lr := &io.LimitedReader{R: r, N: limit + 1}
+	sc := bufio.NewScanner(lr)
+	buf := make([]byte, 0, 64*1024)
+	sc.Buffer(buf, int(limit)+1)
+
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line != "" {
+			if sb.Len() > 0 {
+				sb.WriteByte('\n')
+			}
+			sb.WriteString(line)
+		}
+	}
+	if lr.N == 0 {
+		return "", fmt.Errorf("response body exceeds %d bytes", limit)
+	}
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
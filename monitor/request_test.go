@@ -0,0 +1,40 @@
+package monitor_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leonidSpiri/go-homework/monitor"
+	"github.com/leonidSpiri/go-homework/monitor/monitortest"
+)
+
+func TestFetchRetries(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("1,2,3"))
+	}))
+	defer srv.Close()
+
+	c := monitor.Request{Retries: 2, RetryBaseDelay: time.Hour}
+	body, err := c.Fetch(context.Background(), monitortest.NewClock(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)), srv.Client(), srv.URL)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if body != "1,2,3" || requests != 3 {
+		t.Errorf("Fetch = %q after %d requests, want %q after 3", body, requests, "1,2,3")
+	}
+}
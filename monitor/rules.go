@@ -0,0 +1,99 @@
+package monitor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rule — пользовательская проверка произвольного поля ответа. В сообщениях {name}, {value} и {limit}
+// заменяются на имя правила, значение поля и порог.
+type Rule struct {
+	Name      string  `json:"name"`
+	Field     int     `json:"field"` // номер поля в ответе, с единицы
+	Op        string  `json:"op"`
+	Limit     float64 `json:"limit"`
+	Message   string  `json:"message"`
+	OKMessage string  `json:"ok_message"`
+}
+
+var ruleOps = map[string]func(v, limit float64) bool{
+	">":  func(v, limit float64) bool { return v > limit },
+	">=": func(v, limit float64) bool { return v >= limit },
+	"<":  func(v, limit float64) bool { return v < limit },
+	"<=": func(v, limit float64) bool { return v <= limit },
+	"==": func(v, limit float64) bool { return v == limit },
+	"!=": func(v, limit float64) bool { return v != limit },
+}
+
+var builtinMetrics = map[string]bool{
+	MetricLoadAvg:     true,
+	MetricMemory:      true,
+	MetricDisk:        true,
+	MetricNetwork:     true,
+	MetricSwap:        true,
+	MetricTemperature: true,
+	MetricFetch:       true,
+	MetricHTTPStatus:  true,
+
+	MetricLoadLow:        true,
+	MetricMemoryLow:      true,
+	MetricDiskLow:        true,
+	MetricNetworkLow:     true,
+	MetricSwapLow:        true,
+	MetricTemperatureLow: true,
+}
+
+// ValidateRules проверяет, что у правил заданы имя, поле, условие и
+// сообщение, а имена не повторяются и не совпадают со встроенными метриками.
+func ValidateRules(rules []Rule) error {
+	seen := make(map[string]bool, len(rules))
+	for i, r := range rules {
+		switch {
+		case r.Name == "":
+			return fmt.Errorf("invalid rule #%d: missing name", i+1)
+		case builtinMetrics[r.Name] || seen[r.Name]:
+			return fmt.Errorf("invalid rule %q: duplicate name", r.Name)
+		case r.Field < 1:
+			return fmt.Errorf("invalid rule %q: field must be at least 1", r.Name)
+		case ruleOps[r.Op] == nil:
+			return fmt.Errorf("invalid rule %q: unknown op %q", r.Name, r.Op)
+		case r.Message == "":
+			return fmt.Errorf("invalid rule %q: missing message", r.Name)
+		}
+		seen[r.Name] = true
+	}
+	return nil
+}
+
+// evaluate проверяет правило по разобранным значениям; ok == false,
+// если в ответе нет нужного поля.
+func (r Rule) evaluate(values []float64) (a Alert, ok bool) {
+	if r.Field > len(values) {
+		return Alert{}, false
+	}
+	value := values[r.Field-1]
+	okMsg := r.OKMessage
+	if okMsg == "" {
+		okMsg = "{name} back to normal: {value}"
+	}
+	a = Alert{
+		Metric:    r.Name,
+		Status:    StatusOK,
+		Value:     value,
+		Threshold: r.Limit,
+		Message:   r.expand(okMsg, value),
+		op:        r.Op,
+	}
+	if ruleOps[r.Op](value, r.Limit) {
+		a.Status, a.Severity, a.Message = StatusFiring, SeverityWarning, r.expand(r.Message, value)
+	}
+	return a, true
+}
+
+func (r Rule) expand(tmpl string, value float64) string {
+	return strings.NewReplacer(
+		"{name}", r.Name,
+		"{value}", fmtFloat(value),
+		"{limit}", fmtFloat(r.Limit),
+	).Replace(tmpl)
+}
@@ -0,0 +1,51 @@
+package monitor
+
+import "fmt"
+
+// Stats — разобранный ответ _stats. Поля идут в том же порядке, что и в
+// CSV-формате; необязательные поля присылают не все агенты.
+type Stats struct {
+	LoadAvg     float64
+	MemTotal    float64
+	MemUsed     float64
+	DiskTotal   float64
+	DiskUsed    float64
+	NetCapacity float64 // байт/с
+	NetUsed     float64 // байт/с
+
+	HasSwap   bool
+	SwapTotal float64
+	SwapUsed  float64
+
+	HasTemperature bool
+	Temperature    float64 // градусы Цельсия
+
+	// Fields — все поля ответа по порядку, включая неизвестные; по ним
+	// проверяются пользовательские правила.
+	Fields []float64
+}
+
+// NewStats раскладывает значения по полям Stats.
+func NewStats(values []float64) (Stats, error) {
+	// новые версии агента дописывают поля в конец, лишние игнорируются
+	if len(values) < 7 {
+		return Stats{}, fmt.Errorf("invalid fields count: got %d, want at least 7", len(values))
+	}
+	s := Stats{
+		LoadAvg:     values[0],
+		MemTotal:    values[1],
+		MemUsed:     values[2],
+		DiskTotal:   values[3],
+		DiskUsed:    values[4],
+		NetCapacity: values[5],
+		NetUsed:     values[6],
+		Fields:      values,
+	}
+	if len(values) >= 9 {
+		s.HasSwap, s.SwapTotal, s.SwapUsed = true, values[7], values[8]
+	}
+	if len(values) >= 10 {
+		s.HasTemperature, s.Temperature = true, values[9]
+	}
+	return s, nil
+}
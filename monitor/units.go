@@ -0,0 +1,66 @@
+package monitor
+
+import (
+	"math"
+	"strconv"
+)
+
+// Единицы свободного места на диске в сообщениях, Checks.DiskUnit.
+const (
+	DiskUnitMB   = "mb"
+	DiskUnitGB   = "gb"
+	DiskUnitAuto = "auto"
+)
+
+// Системы единиц для объёмов в сообщениях, Checks.Units.
+const (
+	UnitsLegacy = "legacy" // 1 Mb = 1024 * 1024 байт, подписи как было всегда
+	UnitsBinary = "binary" // 1 MiB = 1024 * 1024 байт
+	UnitsSI     = "si"     // 1 MB = 1000 * 1000 байт
+)
+
+// byteUnits — множитель между соседними единицами и подписи мегабайт,
+// гигабайт и терабайт.
+type byteUnits struct {
+	step       int64
+	mb, gb, tb string
+}
+
+var (
+	legacyUnits = byteUnits{1024, "Mb", "Gb", "Tb"}
+	binaryUnits = byteUnits{1024, "MiB", "GiB", "TiB"}
+	siUnits     = byteUnits{1000, "MB", "GB", "TB"}
+)
+
+// formatDiskSize печатает объём в единицах units, по умолчанию в прежних
+// UnitsLegacy. Мегабайты, как и раньше, выводятся целым числом с
+// отбрасыванием дробной части, крупные единицы — с одним знаком после запятой.
+func formatDiskSize(bytes int64, unit, units string) string {
+	u := legacyUnits
+	switch units {
+	case UnitsBinary:
+		u = binaryUnits
+	case UnitsSI:
+		u = siUnits
+	}
+	mega := u.step * u.step
+	giga := mega * u.step
+	tera := giga * u.step
+	switch unit {
+	case DiskUnitGB:
+		return formatScaled(bytes, giga, u.gb)
+	case DiskUnitAuto:
+		switch {
+		case bytes >= tera:
+			return formatScaled(bytes, tera, u.tb)
+		case bytes >= giga:
+			return formatScaled(bytes, giga, u.gb)
+		}
+	}
+	return strconv.FormatInt(bytes/mega, 10) + " " + u.mb
+}
+
+func formatScaled(bytes, base int64, suffix string) string {
+	v := math.Round(10*float64(bytes)/float64(base)) / 10
+	return fmtFloat(v) + " " + suffix
+}
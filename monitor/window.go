@@ -0,0 +1,55 @@
+package monitor
+
+// sampleWindow хранит последние size значений и отдаёт их среднее.
+type sampleWindow struct {
+	samples []float64
+	next    int
+	full    bool
+}
+
+func newSampleWindow(size int) *sampleWindow {
+	return &sampleWindow{samples: make([]float64, size)}
+}
+
+// add добавляет значение, вытесняя самое старое, и возвращает среднее по
+// окну; пока окно не заполнено, среднее считается по тем, что есть.
+func (w *sampleWindow) add(v float64) float64 {
+	w.samples[w.next] = v
+	w.next = (w.next + 1) % len(w.samples)
+	if w.next == 0 {
+		w.full = true
+	}
+	n := w.next
+	if w.full {
+		n = len(w.samples)
+	}
+	sum := 0.0
+	for _, s := range w.samples[:n] {
+		sum += s
+	}
+	return sum / float64(n)
+}
+
+// ewma сглаживает доли использования экспоненциальным скользящим средним,
+// отдельно по каждой метрике. Нулевой *ewma пропускает значения как есть.
+type ewma struct {
+	alpha float64
+	last  map[string]float64
+}
+
+func newEWMA(alpha float64) *ewma {
+	return &ewma{alpha: alpha, last: make(map[string]float64)}
+}
+
+// add учитывает новое значение с весом alpha и возвращает среднее;
+// первое значение метрики становится средним целиком.
+func (e *ewma) add(metric string, v float64) float64 {
+	if e == nil {
+		return v
+	}
+	if last, ok := e.last[metric]; ok {
+		v = e.alpha*v + (1-e.alpha)*last
+	}
+	e.last[metric] = v
+	return v
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+
+	"github.com/leonidSpiri/go-homework/monitor"
+)
+
+// Экспорт в OpenTelemetry Collector по OTLP/HTTP через SDK метрик: значения
+// последнего опроса каждого сервера отдаются наблюдаемыми gauge, а SDK
+// сам выгружает их с периодом опроса и при завершении.
+
+const (
+	otlpScope   = "github.com/leonidSpiri/go-homework"
+	otlpService = "go-homework"
+)
+
+// otlpSink хранит последние значения по серверам; их читают обратные
+// вызовы gauge при каждом сборе метрик.
+type otlpSink struct {
+	provider *sdkmetric.MeterProvider
+
+	mu     sync.Mutex
+	values map[string]map[string]float64 // метрика -> сервер -> значение
+}
+
+// newOTLPSink регистрирует gauge из promGauges и запускает выгрузку на
+// endpoint каждые interval.
+func newOTLPSink(endpoint string, interval time.Duration) (*otlpSink, error) {
+	exporter, err := otlpmetrichttp.New(context.Background(),
+		otlpmetrichttp.WithEndpointURL(otlpMetricsURL(endpoint)),
+		otlpmetrichttp.WithTimeout(webhookTimeout))
+	if err != nil {
+		return nil, err
+	}
+	// OTEL_SERVICE_NAME и OTEL_RESOURCE_ATTRIBUTES из окружения имеют приоритет
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", otlpService)))
+	if err == nil {
+		res, err = resource.Merge(res, resource.Environment())
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &otlpSink{values: make(map[string]map[string]float64)}
+	s.provider = sdkmetric.NewMeterProvider(
+		sdkmetric.WithResource(res),
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(interval))))
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.Printf("warning: otlp: %v", err)
+	}))
+
+	meter := s.provider.Meter(otlpScope)
+	for _, g := range promGauges {
+		name := g.metric
+		if _, err := meter.Float64ObservableGauge(g.name, metric.WithDescription(g.help),
+			metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+				s.observe(name, o)
+				return nil
+			})); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// send запоминает значения метрик одного опроса.
+func (s *otlpSink) send(alerts []monitor.Alert) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range alerts {
+		byServer := s.values[a.Metric]
+		if byServer == nil {
+			byServer = make(map[string]float64)
+			s.values[a.Metric] = byServer
+		}
+		byServer[a.Server] = a.Value
+	}
+}
+
+func (s *otlpSink) observe(metricName string, o metric.Float64Observer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for server, v := range s.values[metricName] {
+		o.Observe(v, metric.WithAttributes(attribute.String("server", server)))
+	}
+}
+
+// close выгружает последние значения и останавливает экспорт.
+func (s *otlpSink) close() {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	if err := s.provider.Shutdown(ctx); err != nil {
+		log.Printf("warning: otlp: %v", err)
+	}
+}
+
+// otlpMetricsURL дописывает стандартный путь, если в -otlp-endpoint указан
+// только адрес коллектора.
+func otlpMetricsURL(endpoint string) string {
+	u, err := parseHTTPURL("otlp-endpoint", endpoint)
+	if err != nil {
+		return endpoint
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/metrics"
+	}
+	return u.String()
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/leonidSpiri/go-homework/monitor"
+)
+
+func validateCSVFormat(f monitor.CSVFormat) error {
+	if f.Decimal != "." && f.Decimal != "," {
+		return fmt.Errorf("invalid -decimal %q: must be \".\" or \",\"", f.Decimal)
+	}
+	if f.Delim == f.Decimal {
+		return fmt.Errorf("invalid -decimal %q: conflicts with -delimiter", f.Decimal)
+	}
+	switch f.Thousands {
+	case "", ",", ".", "'", " ":
+	default:
+		return fmt.Errorf("invalid -thousands %q: must be \",\", \".\", \"'\" or space", f.Thousands)
+	}
+	if f.Thousands != "" && (f.Thousands == f.Delim || f.Thousands == f.Decimal) {
+		return fmt.Errorf("invalid -thousands %q: conflicts with -delimiter or -decimal", f.Thousands)
+	}
+	return nil
+}
+
+func parseDelimiter(v string) (string, error) {
+	switch v {
+	case ",", ";":
+		return v, nil
+	case "tab", "\t", "\\t":
+		return "\t", nil
+	case "auto":
+		return "", nil
+	}
+	return "", fmt.Errorf("invalid -delimiter %q: must be \",\", \";\", tab or auto", v)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/leonidSpiri/go-homework/monitor"
+)
+
+// evalSettings — настройки проверки, которые меняются по SIGHUP без
+// перезапуска и без потери состояния оповещений. Остальное (адреса,
+// интервалы, вывод) по-прежнему требует перезапуска.
+type evalSettings struct {
+	checks     monitor.Checks
+	hysteresis float64
+}
+
+func newEvalSettings(c config) *evalSettings {
+	return &evalSettings{
+		checks:     c.checks(),
+		hysteresis: c.Hysteresis / 100,
+	}
+}
+
+// current возвращает копию опций с последними перечитанными настройками.
+func (o monitorOptions) current() monitorOptions {
+	if o.live == nil {
+		return o
+	}
+	s := o.live.Load()
+	o.checks = s.checks
+	o.hysteresis = s.hysteresis
+	return o
+}
+
+// handleSIGHUP переоткрывает -log-file и перечитывает конфигурацию.
+// Если новая конфигурация не проходит проверку, остаётся прежняя.
+func handleSIGHUP(alertLog *logFile, live *atomic.Pointer[evalSettings], reload bool) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if alertLog != nil {
+				if err := alertLog.reopen(); err != nil {
+					log.Printf("reopen -log-file: %v", err)
+				}
+			}
+			if !reload {
+				continue
+			}
+			fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			cfg, _, err := loadConfig(fs, os.Args[1:])
+			if err != nil {
+				log.Printf("reload config: %v; keeping the previous settings", err)
+				continue
+			}
+			live.Store(newEvalSettings(cfg))
+			log.Printf("config reloaded: load_limit=%s mem_limit=%s disk_limit=%s net_limit=%s rules=%d",
+				fmtFloat(cfg.LoadLimit), fmtFloat(cfg.MemLimit), fmtFloat(cfg.DiskLimit), fmtFloat(cfg.NetLimit), len(cfg.Rules))
+		}
+	}()
+}
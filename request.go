@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leonidSpiri/go-homework/monitor"
+)
+
+const (
+	defaultRetryDelay   = 200 * time.Millisecond
+	defaultMaxRedirects = 10
+)
+
+// validateRequest проверяет сочетание флагов запроса.
+func validateRequest(c monitor.Request) error {
+	if c.Password != "" && c.User == "" {
+		return errors.New("invalid -password: requires -user")
+	}
+	if c.User != "" && c.Token != "" {
+		return errors.New("invalid -user: cannot be combined with -auth-token")
+	}
+	if c.MaxBodySize <= 0 {
+		return fmt.Errorf("invalid -max-body-size %d: must be positive", c.MaxBodySize)
+	}
+	if c.Retries < 0 {
+		return fmt.Errorf("invalid -retries %d: must be non-negative", c.Retries)
+	}
+	if c.Retries > 0 && c.RetryBaseDelay <= 0 {
+		return fmt.Errorf("invalid -retry-base-delay %s: must be positive", c.RetryBaseDelay)
+	}
+	return nil
+}
+
+// redirectPolicy пропускает не больше max переходов (в отличие от
+// http.Client, который считает запросы вместе с первым) и сообщает о каждом
+// переходе один раз: переезд адреса статистики лучше
+// исправить в конфигурации, а не полагаться на переадресацию.
+func redirectPolicy(max int) func(req *http.Request, via []*http.Request) error {
+	var (
+		mu   sync.Mutex
+		seen = make(map[string]bool)
+	)
+	return func(req *http.Request, via []*http.Request) error {
+		if max == 0 {
+			return http.ErrUseLastResponse
+		}
+		if len(via) > max {
+			return fmt.Errorf("stopped after %d redirects", max)
+		}
+		hop := via[len(via)-1].URL.Redacted() + " -> " + req.URL.Redacted()
+		mu.Lock()
+		defer mu.Unlock()
+		if !seen[hop] {
+			seen[hop] = true
+			log.Printf("warning: redirected: %s", hop)
+		}
+		return nil
+	}
+}
+
+// headerList собирает повторяющийся флаг -header "Key: Value".
+type headerList []string
+
+func (l *headerList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *headerList) Set(v string) error {
+	if _, _, err := parseHeader(v); err != nil {
+		return err
+	}
+	*l = append(*l, v)
+	return nil
+}
+
+func parseHeader(v string) (key, value string, err error) {
+	key, value, ok := strings.Cut(v, ":")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return "", "", fmt.Errorf("malformed header %q: want \"Key: Value\"", v)
+	}
+	return key, strings.TrimSpace(value), nil
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/leonidSpiri/go-homework/monitor"
+)
+
+// monitorOptions — настройки цикла опроса, общие для всех серверов.
+type monitorOptions struct {
+	checks         monitor.Checks
+	interval       time.Duration
+	deadline       time.Duration // предел на весь опрос вместе с разбором; 0 — без предела
+	errThreshold   int
+	fetchErrMsg    string
+	reportFailures bool
+	reportEvery    int  // через сколько опросов печатать счётчики; 0 — не печатать
+	statusAlerts   bool // код ответа, отличный от 200, — отдельная тревога
+	cooldown       time.Duration
+	remindEvery    time.Duration
+	debounce       bool
+	hysteresis     float64
+	consecutive    int
+	loadSamples    int
+	jitter         float64
+	skipMissed     bool
+	firstDelay     time.Duration
+	randomDelay    bool
+	ewmaAlpha      float64
+	verbose        bool
+	jsonSummary    bool // в режиме -once вместо строк оповещений один JSON-объект
+	summary        bool // строка key=value после каждого успешного опроса
+	multiServer    bool // опрашивается больше одного сервера
+	parser         monitor.Parser
+	formatter      alertFormatter
+	out            *log.Logger
+	journal        *journalSink // с -journal оповещения идут в журнал, а не в out
+	errs           *log.Logger  // ошибки опроса, печатаются и с -quiet
+	gauges         *gaugeSet
+	statsd         *statsdSink
+	otlp           *otlpSink // все значения каждого опроса, не только тревоги
+	status         *pollStatus
+	webhooks       []*webhook
+	request        monitor.Request
+	clock          monitor.Clock
+	live           *atomic.Pointer[evalSettings] // nil — без перечитывания по SIGHUP
+}
+
+// newMonitorOptions переносит в опции настройки из конфигурации; парсер,
+// вывод и получатели оповещений подключает main.
+func newMonitorOptions(cfg config, multiServer bool) monitorOptions {
+	return monitorOptions{
+		checks:         cfg.checks(),
+		interval:       time.Duration(cfg.Interval),
+		deadline:       time.Duration(cfg.PollDeadline),
+		jitter:         cfg.Jitter / 100,
+		skipMissed:     cfg.SkipMissed,
+		firstDelay:     time.Duration(cfg.StartDelay),
+		randomDelay:    cfg.RandomStartDelay,
+		errThreshold:   cfg.ErrorThreshold,
+		fetchErrMsg:    cfg.FetchErrorMessage,
+		reportFailures: cfg.ReportFailures,
+		reportEvery:    cfg.ReportEvery,
+		statusAlerts:   cfg.StatusAlerts,
+		cooldown:       time.Duration(cfg.AlertCooldown),
+		remindEvery:    time.Duration(cfg.RemindEvery),
+		debounce:       cfg.Debounce,
+		hysteresis:     cfg.Hysteresis / 100,
+		consecutive:    cfg.AlertConsecutive,
+		loadSamples:    cfg.LoadWindow,
+		ewmaAlpha:      cfg.EWMAAlpha,
+		verbose:        cfg.Verbose,
+		jsonSummary:    cfg.JSONSummary,
+		summary:        cfg.Summary,
+		multiServer:    multiServer,
+		request:        cfg.request(),
+		clock:          monitor.RealClock{},
+	}
+}
+
+func (o monitorOptions) sendWebhooks(alerts []monitor.Alert) {
+	for _, w := range o.webhooks {
+		w.send(alerts)
+	}
+}
+
+func (o monitorOptions) closeWebhooks() {
+	for _, w := range o.webhooks {
+		w.close()
+	}
+	if o.otlp != nil {
+		o.otlp.close()
+	}
+}
+
+// Коды выхода в режиме -once, в духе плагинов Nagios.
+const (
+	exitOK    = 0
+	exitAlert = 1
+	exitError = 2
+)
+
+// runOnce опрашивает каждый сервер один раз и возвращает код выхода:
+// exitError, если хотя бы один опрос завершился ошибкой, exitAlert, если
+// сработал хотя бы один порог, иначе exitOK.
+func runOnce(targets, hosts []string, opts monitorOptions, poll func(target, server string) monitor.Result) int {
+	failed, alerting := false, false
+	var summaries []serverSummary
+	for i, target := range targets {
+		r := poll(target, hosts[i])
+		alerts, err := r.Alerts, r.Err
+		if opts.jsonSummary {
+			summaries = append(summaries, newServerSummary(hosts[i], r.Stats.Fields, alerts, err))
+		}
+		if err != nil {
+			opts.errs.Printf("%s: %v", hosts[i], err)
+			failed = true
+			var se *monitor.StatusError
+			if opts.statusAlerts && errors.As(err, &se) {
+				status := []monitor.Alert{statusAlert(hosts[i], se, opts.clock.Now())}
+				if !opts.jsonSummary {
+					opts.writeAlerts(status)
+				}
+				opts.sendWebhooks(status)
+			}
+			continue
+		}
+		if opts.summary {
+			opts.out.Println(summaryLine(hosts[i], opts.multiServer, alerts))
+		}
+		if opts.statsd != nil {
+			opts.statsd.send(hosts[i], alerts)
+		}
+		if opts.otlp != nil {
+			opts.otlp.send(alerts)
+		}
+		alerts = monitor.Firing(alerts)
+		if len(alerts) > 0 {
+			alerting = true
+		}
+		if !opts.jsonSummary {
+			opts.writeAlerts(alerts)
+		}
+		opts.sendWebhooks(alerts)
+	}
+
+	code := exitOK
+	switch {
+	case failed:
+		code = exitError
+	case alerting:
+		code = exitAlert
+	}
+	if opts.jsonSummary {
+		if err := writeSummary(opts.out.Writer(), code, summaries); err != nil {
+			opts.errs.Printf("write summary: %v", err)
+		}
+	}
+	return code
+}
+
+// watch опрашивает сервер до отмены ctx и возвращает счётчики опросов.
+func watch(ctx context.Context, client *http.Client, url, server string, opts monitorOptions) (counts pollCounts) {
+	errStreak := 0
+	authWarned := false
+	lastStatus := 0 // код ответа последней тревоги -status-alerts, 0 — тревоги нет
+	state := alertState{}
+	breaches := newBreachCounter(opts.consecutive)
+	cooldown := newAlertCooldown(opts.cooldown)
+	reminder := newAlertReminder(opts.remindEvery)
+
+	prefix := ""
+	if f, ok := opts.formatter.(textFormatter); ok && f.withServer {
+		prefix = "[" + server + "] "
+	}
+
+	m := opts.newMonitor(client, url, server)
+	m.OnResult = func(r monitor.Result) {
+		alerts, err := r.Alerts, r.Err
+		switch {
+		case err != nil && opts.reportFailures:
+			// через errs, как и сообщение по -error-threshold: печатается и с -quiet
+			opts.errs.Printf("%spoll failed (%s): %v", prefix, monitor.ErrorCategory(err), err)
+		case err != nil && opts.verbose:
+			log.Printf("%s: poll failed (%s): %v", server, monitor.ErrorCategory(err), err)
+		}
+		counts.record(err)
+		if opts.status != nil {
+			opts.status.count(server, counts)
+		}
+		if opts.reportEvery > 0 && counts.Polls%opts.reportEvery == 0 {
+			log.Printf("%s: %s", server, counts)
+		}
+		var se *monitor.StatusError
+		if opts.statusAlerts && errors.As(err, &se) {
+			// тревога по коду ответа сразу, без -error-threshold; с -debounce
+			// повторяется, только когда код сменился
+			if !opts.debounce || se.Code != lastStatus {
+				failed := cooldown.filter([]monitor.Alert{statusAlert(server, se, opts.clock.Now())}, opts.clock.Now())
+				opts.writeAlerts(failed)
+				opts.sendWebhooks(failed)
+			}
+			lastStatus = se.Code
+			return
+		}
+		if err != nil {
+			// об ошибке авторизации сообщаем сразу, один раз до первого успешного опроса
+			if errors.Is(err, monitor.ErrAuthFailed) && !authWarned && !opts.reportFailures {
+				opts.errs.Printf("%s%v", prefix, err)
+				authWarned = true
+			}
+			errStreak++
+			if errStreak >= opts.errThreshold {
+				failed := []monitor.Alert{fetchFailedAlert(server, opts.fetchErrMsg, errStreak, opts.errThreshold, err, opts.clock.Now())}
+				opts.writeAlerts(failed)
+				opts.sendWebhooks(failed)
+				opts.errs.Printf("%slast error (%s): %v", prefix, monitor.ErrorCategory(err), err)
+				errStreak = 0
+			}
+			return
+		}
+
+		errStreak = 0
+		authWarned = false
+		if lastStatus != 0 && opts.debounce {
+			resolved := []monitor.Alert{statusResolvedAlert(server, opts.clock.Now())}
+			opts.writeAlerts(resolved)
+			opts.sendWebhooks(resolved)
+		}
+		lastStatus = 0
+		if opts.summary {
+			opts.out.Println(summaryLine(server, opts.multiServer, alerts))
+		}
+		if opts.statsd != nil {
+			opts.statsd.send(server, alerts)
+		}
+		if opts.otlp != nil {
+			opts.otlp.send(alerts)
+		}
+		checked := alerts
+		alerts = breaches.filter(alerts)
+		// gauge тревоги и /status повторяют решение, по которому печатаются оповещения
+		var firing []monitor.Alert
+		if opts.debounce {
+			changed := state.update(alerts, opts.current().hysteresis)
+			firing = state.firing(alerts)
+			// напоминания и так не чаще -remind-every, -alert-cooldown их не глушит
+			reminders := reminder.remind(alerts, changed, opts.clock.Now())
+			alerts = append(cooldown.filter(changed, opts.clock.Now()), reminders...)
+		} else {
+			alerts = monitor.Firing(alerts)
+			firing = alerts
+			alerts = cooldown.filter(alerts, opts.clock.Now())
+		}
+		if opts.gauges != nil {
+			opts.gauges.update(server, checked, firing)
+		}
+		if opts.status != nil {
+			opts.status.success(server, checked, firing)
+		}
+		opts.writeAlerts(alerts)
+		opts.sendWebhooks(alerts)
+	}
+	m.Run(ctx)
+	return counts
+}
+
+// newMonitor собирает опрос одного сервера из общих настроек; с
+// перечитыванием по SIGHUP пороги берутся из последней конфигурации.
+func (o monitorOptions) newMonitor(client *http.Client, url, server string) *monitor.Monitor {
+	m := &monitor.Monitor{
+		URL:              url,
+		Server:           server,
+		Client:           client,
+		Request:          o.request,
+		Parser:           o.parser,
+		Checks:           o.checks,
+		Interval:         o.interval,
+		Deadline:         o.deadline,
+		Jitter:           o.jitter,
+		StartDelay:       o.firstDelay,
+		RandomStartDelay: o.randomDelay,
+		SkipMissed:       o.skipMissed,
+		LoadWindow:       o.loadSamples,
+		EWMAAlpha:        o.ewmaAlpha,
+		Verbose:          o.verbose,
+		Clock:            o.clock,
+	}
+	if o.live != nil {
+		m.Settings = func() monitor.Checks { return o.current().checks }
+	}
+	return m
+}
+
+// writeAlerts печатает оповещения в out или, с -journal, пишет их в журнал;
+// если журнал недоступен, оповещение всё равно печатается.
+func (o monitorOptions) writeAlerts(alerts []monitor.Alert) {
+	if o.journal == nil {
+		writeAlerts(o.out, o.formatter, alerts)
+		return
+	}
+	for _, a := range alerts {
+		if err := o.journal.sendAlert(o.formatter, a); err != nil {
+			log.Printf("warning: journal: %v", err)
+			writeAlerts(o.out, o.formatter, []monitor.Alert{a})
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// servicePathFlags — флаги с путями к файлам. Диспетчер служб запускает
+// службу из C:\Windows\System32, поэтому при установке пути делаются
+// абсолютными.
+var servicePathFlags = map[string]bool{
+	"config":      true,
+	"log-file":    true,
+	"ca-cert":     true,
+	"client-cert": true,
+	"client-key":  true,
+}
+
+// serviceArgs готовит аргументы, с которыми будет запускаться служба:
+// убирает -service и делает пути из servicePathFlags абсолютными. Остальные
+// значения флагов и всё после "--" переносятся как есть: значение, которое
+// случайно совпало с "service", не теряется.
+func serviceArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return append(out, args[i:]...), nil
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			// команда check или watch
+			out = append(out, arg)
+			continue
+		}
+		start := i
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if f := fs.Lookup(name); f != nil && !hasValue && !isBoolFlag(f) && i+1 < len(args) {
+			i++ // значение отдельным аргументом
+			value, hasValue = args[i], true
+		}
+		switch {
+		case name == "service":
+		case servicePathFlags[name] && hasValue && value != "":
+			abs, err := filepath.Abs(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid -%s %q: %w", name, value, err)
+			}
+			out = append(out, "-"+name+"="+abs)
+		default:
+			out = append(out, args[start:i+1]...)
+		}
+	}
+	return out, nil
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// serviceContext вне Windows ничего не делает: службами управляет
+// systemd или другой супервизор.
+func serviceContext(ctx context.Context) (context.Context, func()) {
+	return ctx, func() {}
+}
+
+func controlService(string, []string) error {
+	return errors.New("invalid -service: Windows services are supported only on Windows")
+}
@@ -0,0 +1,113 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// service отвечает диспетчеру служб, пока main опрашивает серверы:
+// по Stop или Shutdown отменяет ctx и ждёт, пока опросы завершатся.
+type service struct {
+	cancel   context.CancelFunc
+	finished <-chan struct{}
+}
+
+func (s *service) Execute(_ []string, r <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case <-s.finished:
+			return false, 0
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				status <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				s.cancel()
+				<-s.finished
+				return false, 0
+			}
+		}
+	}
+}
+
+// serviceContext, если процесс запущен как служба Windows, регистрирует
+// его у диспетчера служб. Возвращённый ctx отменяется при остановке службы,
+// а done нужно вызвать, когда опросы завершились.
+func serviceContext(ctx context.Context) (context.Context, func()) {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	finished := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		if err := svc.Run(serviceName, &service{cancel: cancel, finished: finished}); err != nil {
+			log.Printf("windows service: %v", err)
+		}
+		cancel()
+	}()
+	return ctx, func() {
+		close(finished)
+		<-stopped
+	}
+}
+
+// controlService выполняет -service: установку, удаление, запуск или
+// остановку службы. Служба запускается с теми же флагами, что и install.
+func controlService(action string, args []string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("-service %s: %w", action, err)
+	}
+	defer m.Disconnect()
+
+	if action == "install" {
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("-service install: %w", err)
+		}
+		if exe, err = filepath.Abs(exe); err != nil {
+			return fmt.Errorf("-service install: %w", err)
+		}
+		s, err := m.CreateService(serviceName, exe, mgr.Config{
+			DisplayName: "Server statistics monitor",
+			StartType:   mgr.StartAutomatic,
+		}, args...)
+		if err != nil {
+			return fmt.Errorf("-service install: %w", err)
+		}
+		s.Close()
+		return nil
+	}
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("-service %s: %w", action, err)
+	}
+	defer s.Close()
+	switch action {
+	case "uninstall":
+		err = s.Delete()
+	case "start":
+		err = s.Start()
+	case "stop":
+		_, err = s.Control(svc.Stop)
+	}
+	if err != nil {
+		return fmt.Errorf("-service %s: %w", action, err)
+	}
+	return nil
+}
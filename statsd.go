@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/leonidSpiri/go-homework/monitor"
+)
+
+// statsdUnsafe — символы, которые в имени метрики StatsD/Graphite означают
+// разделитель или недопустимы; в имени сервера они заменяются на "_".
+var statsdUnsafe = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// statsdSink шлёт значения метрик gauge-ами StatsD по UDP, одним пакетом
+// на опрос. Имена — <prefix>.<server>.<metric>, как у -metrics-addr.
+type statsdSink struct {
+	conn   net.Conn
+	prefix string
+	names  map[string]string // метрика -> имя gauge
+}
+
+func newStatsdSink(addr, prefix string) (*statsdSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -statsd-addr %q: %w", addr, err)
+	}
+	names := make(map[string]string, len(promGauges))
+	for _, g := range promGauges {
+		names[g.metric] = g.name
+	}
+	return &statsdSink{conn: conn, prefix: strings.TrimSuffix(prefix, "."), names: names}, nil
+}
+
+func (s *statsdSink) send(server string, alerts []monitor.Alert) {
+	node := statsdUnsafe.ReplaceAllString(server, "_")
+
+	var lines []string
+	for _, a := range alerts {
+		name, ok := s.names[a.Metric]
+		if !ok {
+			continue
+		}
+		if s.prefix != "" {
+			name = s.prefix + "." + node + "." + name
+		} else {
+			name = node + "." + name
+		}
+		lines = append(lines, name+":"+fmtFloat(a.Value)+"|g")
+	}
+	if len(lines) == 0 {
+		return
+	}
+	if _, err := s.conn.Write([]byte(strings.Join(lines, "\n"))); err != nil {
+		log.Printf("warning: statsd: %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/leonidSpiri/go-homework/monitor"
+)
+
+// pollStatus хранит результат последнего успешного опроса каждого сервера:
+// время опроса и проверенные метрики. Пока опросов ещё не было, отсчёт
+// времени идёт от запуска.
+type pollStatus struct {
+	mu      sync.Mutex
+	clock   monitor.Clock
+	maxAge  time.Duration
+	last    map[string]time.Time
+	results map[string][]monitor.Alert
+	counts  map[string]pollCounts
+}
+
+// pollCounts — счётчики опросов одного сервера с момента запуска.
+type pollCounts struct {
+	Polls       int `json:"polls"`
+	Successes   int `json:"successes"`
+	FetchErrors int `json:"fetch_errors"`
+	ParseErrors int `json:"parse_errors"`
+}
+
+func (c *pollCounts) record(err error) {
+	c.Polls++
+	var pe monitor.ParseError
+	switch {
+	case err == nil:
+		c.Successes++
+	case errors.As(err, &pe):
+		c.ParseErrors++
+	default:
+		c.FetchErrors++
+	}
+}
+
+func (c pollCounts) String() string {
+	return fmt.Sprintf("polls=%d ok=%d fetch_errors=%d parse_errors=%d success_rate=%.1f%%",
+		c.Polls, c.Successes, c.FetchErrors, c.ParseErrors, 100*float64(c.Successes)/float64(max(c.Polls, 1)))
+}
+
+func newPollStatus(clk monitor.Clock, maxAge time.Duration, servers []string) *pollStatus {
+	s := &pollStatus{
+		clock:   clk,
+		maxAge:  maxAge,
+		last:    make(map[string]time.Time, len(servers)),
+		results: make(map[string][]monitor.Alert, len(servers)),
+		counts:  make(map[string]pollCounts, len(servers)),
+	}
+	started := clk.Now()
+	for _, server := range servers {
+		s.last[server] = started
+	}
+	return s
+}
+
+// success получает все результаты проверок, а не только сработавшие, и
+// тревоги, которые держатся после -alert-consecutive и -hysteresis: по ним,
+// а не по сырому результату проверки, метрика считается alerting.
+func (s *pollStatus) success(server string, alerts, firing []monitor.Alert) {
+	active := make(map[string]string, len(firing))
+	for _, a := range firing {
+		active[a.Metric] = a.Severity
+	}
+	results := make([]monitor.Alert, len(alerts))
+	for i, a := range alerts {
+		a.Status, a.Severity = monitor.StatusOK, ""
+		if severity, ok := active[a.Metric]; ok {
+			a.Status, a.Severity = monitor.StatusFiring, severity
+		}
+		results[i] = a
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last[server] = s.clock.Now()
+	s.results[server] = results
+}
+
+func (s *pollStatus) count(server string, c pollCounts) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts[server] = c
+}
+
+// stale возвращает серверы, успешный опрос которых был раньше maxAge.
+func (s *pollStatus) stale() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	var out []string
+	for server, t := range s.last {
+		if now.Sub(t) > s.maxAge {
+			out = append(out, server)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (s *pollStatus) serveHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	stale := s.stale()
+	if len(stale) == 0 {
+		fmt.Fprintln(w, "ok")
+		return
+	}
+	w.WriteHeader(http.StatusServiceUnavailable)
+	for _, server := range stale {
+		fmt.Fprintf(w, "%s: no successful poll in the last %s\n", server, s.maxAge)
+	}
+}
+
+type metricStatus struct {
+	Metric    string  `json:"metric"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	Alerting  bool    `json:"alerting"`
+	Severity  string  `json:"severity,omitempty"`
+}
+
+type serverStatus struct {
+	Server   string         `json:"server"`
+	LastPoll *time.Time     `json:"last_poll"`
+	Counts   pollCounts     `json:"counts"`
+	Metrics  []metricStatus `json:"metrics"`
+}
+
+func (s *pollStatus) serveStatus(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	servers := make([]serverStatus, 0, len(s.last))
+	for server := range s.last {
+		st := serverStatus{Server: server, Counts: s.counts[server], Metrics: []metricStatus{}}
+		// до первого успешного опроса last_poll остаётся null
+		if alerts, ok := s.results[server]; ok {
+			t := s.last[server]
+			st.LastPoll = &t
+			for _, a := range alerts {
+				st.Metrics = append(st.Metrics, metricStatus{
+					Metric:    a.Metric,
+					Value:     a.Value,
+					Threshold: a.Threshold,
+					Alerting:  a.Status == monitor.StatusFiring,
+					Severity:  a.Severity,
+				})
+			}
+		}
+		servers = append(servers, st)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(servers, func(i, j int) bool { return servers[i].Server < servers[j].Server })
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Servers []serverStatus `json:"servers"`
+	}{servers})
+}
+
+func serveStatus(ctx context.Context, addr string, s *pollStatus) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.serveHealth)
+	mux.HandleFunc("/status", s.serveStatus)
+	return serve(ctx, "health-addr", addr, mux)
+}
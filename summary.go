@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/leonidSpiri/go-homework/monitor"
+)
+
+// statsFields — имена полей ответа _stats по порядку, как в JSON-формате.
+var statsFields = []string{
+	"load", "mem_total", "mem_used", "disk_total", "disk_used", "net_capacity", "net_used",
+	"swap_total", "swap_used", "temperature",
+}
+
+type serverSummary struct {
+	Server  string             `json:"server"`
+	Error   string             `json:"error,omitempty"`
+	Values  map[string]float64 `json:"values,omitempty"`
+	Metrics []metricStatus     `json:"metrics"`
+}
+
+func newServerSummary(server string, values []float64, alerts []monitor.Alert, err error) serverSummary {
+	s := serverSummary{Server: server, Metrics: []metricStatus{}}
+	if err != nil {
+		s.Error = err.Error()
+		return s
+	}
+	s.Values = make(map[string]float64, len(values))
+	for i, v := range values {
+		if i < len(statsFields) {
+			s.Values[statsFields[i]] = v
+		}
+	}
+	for _, a := range alerts {
+		s.Metrics = append(s.Metrics, metricStatus{
+			Metric:    a.Metric,
+			Value:     a.Value,
+			Threshold: a.Threshold,
+			Alerting:  a.Status == monitor.StatusFiring,
+			Severity:  a.Severity,
+		})
+	}
+	return s
+}
+
+// summaryKeys — короткие имена метрик в строке -summary. Нижние границы
+// повторяли бы значение своей метрики, поэтому не печатаются.
+var summaryKeys = map[string]string{
+	monitor.MetricLoadAvg:     "load",
+	monitor.MetricMemory:      "mem",
+	monitor.MetricDisk:        "disk",
+	monitor.MetricNetwork:     "net",
+	monitor.MetricSwap:        "swap",
+	monitor.MetricTemperature: "temp",
+
+	monitor.MetricLoadLow:        "",
+	monitor.MetricMemoryLow:      "",
+	monitor.MetricDiskLow:        "",
+	monitor.MetricNetworkLow:     "",
+	monitor.MetricSwapLow:        "",
+	monitor.MetricTemperatureLow: "",
+}
+
+// summaryLine собирает строку key=value по всем проверенным метрикам
+// в порядке проверки; доли печатаются с двумя знаками после точки.
+func summaryLine(server string, withServer bool, alerts []monitor.Alert) string {
+	fields := make([]string, 0, len(alerts)+1)
+	if withServer {
+		fields = append(fields, "server="+server)
+	}
+	for _, a := range alerts {
+		key, ok := summaryKeys[a.Metric]
+		if !ok {
+			key = a.Metric
+		} else if key == "" {
+			continue
+		}
+		value := fmtFloat(a.Value)
+		switch a.Metric {
+		case monitor.MetricMemory, monitor.MetricDisk, monitor.MetricNetwork, monitor.MetricSwap:
+			value = strconv.FormatFloat(a.Value, 'f', 2, 64)
+		}
+		fields = append(fields, key+"="+value)
+	}
+	return strings.Join(fields, " ")
+}
+
+// writeSummary печатает итог -once одним JSON-объектом; status — то же,
+// что и код выхода: ok, alert или error.
+func writeSummary(w io.Writer, code int, servers []serverSummary) error {
+	status := map[int]string{exitOK: "ok", exitAlert: "alert", exitError: "error"}[code]
+	return json.NewEncoder(w).Encode(struct {
+		Status  string          `json:"status"`
+		Servers []serverSummary `json:"servers"`
+	}{status, servers})
+}
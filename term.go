@@ -0,0 +1,23 @@
+package main
+
+import "os"
+
+const (
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiReset = "\x1b[0m"
+)
+
+// colorEnabled решает, раскрашивать ли вывод: только для терминала и
+// только если цвет не отключён через -no-color или NO_COLOR.
+func colorEnabled(f *os.File, noColor bool) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func colorize(s, color string) string {
+	return color + s + ansiReset
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// newClients возвращает клиента для опроса серверов статистики и отдельного
+// клиента для получателей оповещений. -insecure, -ca-cert, -client-cert,
+// -dns-server и -max-redirects нужны для агентов статистики, получатели же —
+// сторонние сервисы: к ним запросы идут через стандартный транспорт с
+// проверкой сертификатов, имена разрешает системный DNS, а переадресация
+// обрабатывается как в http.Client по умолчанию.
+func newClients(c config) (stats, sinks *http.Client, err error) {
+	transport, err := newTransport(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	stats = &http.Client{
+		Transport:     transport,
+		Timeout:       time.Duration(c.Timeout),
+		CheckRedirect: redirectPolicy(c.MaxRedirects),
+	}
+	return stats, &http.Client{Timeout: time.Duration(c.Timeout)}, nil
+}
+
+// newTransport собирает транспорт для запросов статистики на основе
+// стандартного, добавляя к нему настройки keep-alive, DNS и TLS из конфигурации.
+func newTransport(c config) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	// опросы одного адреса идут по очереди, так что на хост хватает
+	// соединения на каждый его адрес в -url; общий лимит не должен вытеснять
+	// соединения при сотнях серверов, а таймаут простоя — закрывать их
+	// между опросами
+	t.MaxIdleConnsPerHost = max(http.DefaultMaxIdleConnsPerHost, maxTargetsPerHost(c.URLs))
+	t.MaxIdleConns = c.MaxIdleConns
+	if t.MaxIdleConns == 0 {
+		t.MaxIdleConns = max(100, len(c.URLs))
+	}
+	t.IdleConnTimeout = time.Duration(c.IdleConnTimeout)
+	if t.IdleConnTimeout == 0 {
+		t.IdleConnTimeout = max(90*time.Second, 2*time.Duration(c.Interval))
+	}
+	if c.DNSServer != "" {
+		addr, err := dnsServerAddr(c.DNSServer)
+		if err != nil {
+			return nil, err
+		}
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Resolver:  newResolver(addr),
+		}
+		t.DialContext = dialer.DialContext
+	}
+	t.DialContext = withUnixSockets(c.URLs, t.DialContext)
+	if c.CACert == "" && c.ClientCert == "" && !c.Insecure {
+		return t, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.Insecure}
+	if c.CACert != "" {
+		pem, err := os.ReadFile(c.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("read -ca-cert: %w", err)
+		}
+		// внутренний CA добавляется к системным, а не заменяет их
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("invalid -ca-cert %s: no PEM certificates found", c.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(c.ClientCert, c.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("load -client-cert/-client-key: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	t.TLSClientConfig = tlsConfig
+	return t, nil
+}
+
+// maxTargetsPerHost возвращает наибольшее число адресов статистики на
+// одном хосте или unix-сокете.
+func maxTargetsPerHost(urls []string) int {
+	perHost := make(map[string]int)
+	n := 0
+	for _, raw := range urls {
+		host, _, ok := splitUnixURL(raw)
+		if !ok {
+			u, err := url.Parse(raw)
+			if err != nil {
+				continue
+			}
+			host = u.Host
+		}
+		perHost[host]++
+		n = max(n, perHost[host])
+	}
+	return n
+}
+
+// newResolver отправляет все DNS-запросы на addr, минуя системные настройки:
+// так имя сервера статистики разрешается нужным DNS при split-horizon.
+func newResolver(addr string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+}
+
+// dnsServerAddr дополняет адрес DNS-сервера портом 53, если порт не указан.
+func dnsServerAddr(v string) (string, error) {
+	if _, _, err := net.SplitHostPort(v); err == nil {
+		return v, nil
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(v, "["), "]")
+	if net.ParseIP(host) == nil && (host == "" || strings.Contains(host, ":")) {
+		return "", fmt.Errorf("invalid -dns-server %q: want host or host:port", v)
+	}
+	return net.JoinHostPort(host, "53"), nil
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/leonidSpiri/go-homework/monitor"
+)
+
+func validateDiskUnit(unit string) error {
+	switch unit {
+	case monitor.DiskUnitMB, monitor.DiskUnitGB, monitor.DiskUnitAuto:
+		return nil
+	}
+	return fmt.Errorf("invalid -disk-unit %q: must be mb, gb or auto", unit)
+}
+
+func fmtFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
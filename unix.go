@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// serverTargets возвращает для каждого -url адрес запроса, имя сервера
+// в оповещениях и адрес для лога. У сокета имя сервера — путь к нему,
+// а запрос идёт на подставной хост; пароль из user:pass@ в лог не попадает.
+func serverTargets(urls []string) (targets, hosts, shown []string) {
+	targets = make([]string, len(urls))
+	hosts = make([]string, len(urls))
+	shown = make([]string, len(urls))
+	for i, raw := range urls {
+		if socket, path, ok := splitUnixURL(raw); ok {
+			targets[i], hosts[i], shown[i] = "http://"+unixHost(i)+path, socket, raw
+			continue
+		}
+		u, _ := parseHTTPURL("url", raw)
+		targets[i], hosts[i], shown[i] = raw, u.Host, u.Redacted()
+	}
+	return targets, hosts, shown
+}
+
+// splitUnixURL разбирает адрес вида unix:///var/run/agent.sock:/_stats
+// на путь к сокету и путь запроса; без пути запроса берётся "/".
+func splitUnixURL(raw string) (socket, path string, ok bool) {
+	rest, ok := strings.CutPrefix(raw, "unix://")
+	if !ok {
+		return "", "", false
+	}
+	if i := strings.Index(rest, ":/"); i >= 0 {
+		return rest[:i], rest[i+1:], true
+	}
+	return rest, "/", true
+}
+
+// unixHost — имя, под которым i-й из -url сокетов передаётся в http.Client;
+// по нему транспорт находит, какой сокет открыть.
+func unixHost(i int) string {
+	return fmt.Sprintf("unix-socket-%d", i)
+}
+
+// withUnixSockets направляет соединения с хостами unixHost в сокеты из urls,
+// остальные — в dial.
+func withUnixSockets(urls []string, dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	sockets := make(map[string]string)
+	for i, raw := range urls {
+		if socket, _, ok := splitUnixURL(raw); ok {
+			sockets[net.JoinHostPort(unixHost(i), "80")] = socket
+		}
+	}
+	if len(sockets) == 0 {
+		return dial
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if socket, ok := sockets[addr]; ok {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}
+		return dial(ctx, network, addr)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/leonidSpiri/go-homework/monitor"
+)
+
+const (
+	webhookTimeout   = 5 * time.Second
+	webhookQueueSize = 16
+)
+
+// webhookEncoder превращает оповещения одного опроса в тела запросов,
+// каждое тело отправляется отдельным POST.
+type webhookEncoder func([]monitor.Alert) ([][]byte, error)
+
+// webhook отправляет оповещения POST-запросами в отдельной горутине, чтобы
+// медленный приёмник не задерживал цикл опроса.
+type webhook struct {
+	name   string
+	client *http.Client
+	url    string
+	encode webhookEncoder
+	queue  chan []monitor.Alert
+	done   chan struct{}
+}
+
+func newWebhook(name string, client *http.Client, url string, encode webhookEncoder) *webhook {
+	w := &webhook{
+		name:   name,
+		client: client,
+		url:    url,
+		encode: encode,
+		queue:  make(chan []monitor.Alert, webhookQueueSize),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// send не блокируется: если очередь переполнена, оповещения отбрасываются
+// с предупреждением.
+func (w *webhook) send(alerts []monitor.Alert) {
+	if len(alerts) == 0 {
+		return
+	}
+	select {
+	case w.queue <- alerts:
+	default:
+		log.Printf("warning: %s: queue is full, dropping %d alert(s)", w.name, len(alerts))
+	}
+}
+
+// close дожидается отправки всего, что уже стоит в очереди.
+func (w *webhook) close() {
+	close(w.queue)
+	<-w.done
+}
+
+func (w *webhook) run() {
+	defer close(w.done)
+	for alerts := range w.queue {
+		bodies, err := w.encode(alerts)
+		if err != nil {
+			log.Printf("warning: %s: %v", w.name, err)
+			continue
+		}
+		for _, body := range bodies {
+			if err := w.post(body); err != nil {
+				log.Printf("warning: %s: %v", w.name, err)
+			}
+		}
+	}
+}
+
+func (w *webhook) post(body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+// encodeSlack собирает все оповещения одного опроса в одно сообщение
+// для Slack incoming webhook.
+func encodeSlack(alerts []monitor.Alert) ([][]byte, error) {
+	f := textFormatter{withServer: true}
+	lines := make([]string, 0, len(alerts))
+	for _, a := range alerts {
+		line, err := f.format(a)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	body, err := json.Marshal(struct {
+		Text string `json:"text"`
+	}{Text: strings.Join(lines, "\n")})
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{body}, nil
+}
+
+// encodeAlertJSON отправляет каждое оповещение отдельным JSON-объектом,
+// в том же виде, что и -format=json.
+func encodeAlertJSON(alerts []monitor.Alert) ([][]byte, error) {
+	bodies := make([][]byte, 0, len(alerts))
+	for _, a := range alerts {
+		body, err := json.Marshal(a)
+		if err != nil {
+			return nil, fmt.Errorf("encode %s alert: %w", a.Metric, err)
+		}
+		bodies = append(bodies, body)
+	}
+	return bodies, nil
+}